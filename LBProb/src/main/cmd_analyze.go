@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+	"pwlb"
+)
+
+// Prints measures of the precedence graph used to characterize an instance
+func runAnalyze(args []string) {
+	fs, cycle_time := newSpecFlagSet("analyze")
+	fs.Parse(args)
+
+	tc := fillTaskContainer(fs.Args(), *cycle_time)
+	defer tc.Clear()
+
+	fmt.Print(pwlb.PrettyGraphMetricsStr(tc.GetGraphMetrics()))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"pwlb"
+	"strings"
+	"time"
+)
+
+// Runs solvers over every spec in a directory and prints a summary table
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	solver_names := fs.String("solvers", "sst", "comma separated solvers to run: "+
+		strings.Join(pwlb.GetSolverNames(), ", "))
+	cycle_time := fs.Float64("c", pwlb.GetTaskContainer().GetCycleTime(), "cycle time of each workstation")
+	timeout := fs.Duration("timeout", 10*time.Second, "time limit per solver and spec, 0 for none")
+	optima_path := fs.String("optima", "", "file of known optima with lines like spec5.txt,50,8")
+	format := fs.String("format", "md", "output format: md or csv")
+	out_path := fs.String("o", "", "file to write the summary to instead of stdout")
+	layout_name := fs.String("line", "straight", "line layout: straight, u or two-sided")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: bench [flags] spec_dir")
+	}
+	if *format != "md" && *format != "csv" {
+		log.Fatal("Unknown format " + *format + ", expected md or csv")
+	}
+	layout, err := pwlb.ParseLineLayout(*layout_name)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	results, err := pwlb.RunBench(pwlb.BenchConfig{
+		SpecDir:    fs.Arg(0),
+		Solvers:    strings.Split(*solver_names, ","),
+		CycleTime:  *cycle_time,
+		Timeout:    *timeout,
+		OptimaPath: *optima_path,
+		Layout:     layout,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	summary := pwlb.BenchResultsToMarkdown(results)
+	if *format == "csv" {
+		summary = pwlb.BenchResultsToCSV(results)
+	}
+
+	if *out_path == "" {
+		fmt.Print(summary)
+		return
+	}
+	if err := ioutil.WriteFile(*out_path, []byte(summary), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"pwlb"
+	"strconv"
+)
+
+// Assigns the workers listed in a spec to stations and the tasks to them, and
+// prints the shortest cycle time found with the solution at that cycle time
+func runCrew(args []string) {
+	fs := flag.NewFlagSet("crew", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 0, "time limit of the search, 0 for none")
+	fs.Parse(args)
+
+	ctx, cancel := newSolveContext(*timeout)
+	defer cancel()
+
+	tc := fillTaskContainer(fs.Args(), pwlb.GetTaskContainer().GetCycleTime())
+	defer tc.Clear()
+
+	result, err := pwlb.AssignCrew(ctx, tc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if result.GetStatus() == pwlb.SolveInterrupted {
+		fmt.Fprintln(os.Stderr, "WARNING Search interrupted, a shorter cycle time may be possible")
+	}
+	sol := result.GetSolution()
+
+	// The metrics are at the cycle time found
+	tc.SetCycleTime(result.GetCycleTime())
+	wsc := pwlb.GetWorkstationContainer()
+	wsc.FillFrom(tc)
+	defer wsc.Clear()
+	wsc.FillFromSolution(sol)
+
+	fmt.Println("workers=" + strconv.Itoa(len(tc.GetWorkers())))
+	fmt.Println("cycle_time=" + strconv.FormatFloat(result.GetCycleTime(), 'f', 2, 64))
+	fmt.Println("measured_min=" + strconv.Itoa(sol.GetMeasuredMin()))
+	fmt.Println("line_efficiency=" + sol.GetLineEfficiencyStr())
+	fmt.Println("smoothness_index=" + sol.GetSmoothnessIndexStr())
+	fmt.Print("\n\n")
+
+	fmt.Print(pwlb.PrettySolutionStr(sol))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"pwlb"
+	"strconv"
+)
+
+// Compares two saved solutions of the same spec and prints the tasks that moved,
+// the stations added or removed and the change in each metric
+func runDiff(args []string) {
+	fs, cycle_time := newSpecFlagSet("diff")
+	fs.Parse(args)
+
+	if fs.NArg() != 3 {
+		log.Fatal("Usage: diff [flags] spec before_solution after_solution")
+	}
+
+	tc := fillTaskContainer(fs.Args()[:1], *cycle_time)
+	defer tc.Clear()
+
+	diff, err := pwlb.DiffSolutions(readSolution(tc, fs.Arg(1)), readSolution(tc, fs.Arg(2)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("moved_tasks=" + strconv.Itoa(len(diff.GetMoves())))
+	fmt.Println("added_stations=" + strconv.Itoa(len(diff.GetAddedStations())))
+	fmt.Println("removed_stations=" + strconv.Itoa(len(diff.GetRemovedStations())))
+	fmt.Print(pwlb.PrettyDiffMetricsStr(diff))
+	fmt.Print("\n\n")
+
+	fmt.Print(pwlb.PrettyDiffStr(diff))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"pwlb"
+	"strconv"
+	"strings"
+)
+
+// Runs units through the line of a saved solution, with buffers between the
+// stations, and prints the throughput, work in progress and how each station
+// spent its time
+func runFlow(args []string) {
+	fs, cycle_time := newSpecFlagSet("flow")
+	units := fs.Int("units", 10000, "number of units sent down the line")
+	buffers_str := fs.String("buffers", "0", "units each buffer between stations holds, one for all or comma separated per gap")
+	paced := fs.Bool("paced", false, "move every unit at once each cycle instead of as soon as there is room")
+	seed := fs.Int64("seed", 1, "random seed, the same seed gives the same result")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		log.Fatal("Usage: flow [flags] spec solution")
+	}
+
+	var buffers []int
+	for _, size_str := range strings.Split(*buffers_str, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(size_str))
+		if err != nil {
+			log.Fatal("Improper buffer size " + size_str)
+		}
+		buffers = append(buffers, size)
+	}
+
+	tc := fillTaskContainer(fs.Args()[:1], *cycle_time)
+	defer tc.Clear()
+	sol := readSolution(tc, fs.Arg(1))
+
+	fr, err := pwlb.SimulateFlow(sol, pwlb.FlowConfig{Units: *units, Buffers: buffers, Paced: *paced, Seed: *seed})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("units=" + strconv.Itoa(fr.GetUnits()))
+	fmt.Println("total_time=" + strconv.FormatFloat(fr.GetTotalTime(), 'f', 2, 64))
+	fmt.Println("throughput=" + strconv.FormatFloat(fr.GetThroughput(), 'g', 4, 64))
+	fmt.Println("throughput_share=" + strconv.FormatFloat(fr.GetThroughputShare()*100.0, 'f', 1, 64) + "%")
+	fmt.Println("mean_wip=" + strconv.FormatFloat(fr.GetMeanWIP(), 'f', 2, 64))
+	fmt.Print("\n\n")
+
+	fmt.Print(pwlb.PrettyFlowStr(fr))
+}
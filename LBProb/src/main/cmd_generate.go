@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"pwlb"
+	"strings"
+)
+
+// Writes a random spec to the path given or to stdout
+func runGenerate(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	num_tasks := fs.Int("n", 100, "number of tasks")
+	order_strength := fs.Float64("os", 0.5, "target order strength from 0 to 1")
+	times := fs.String("times", "uniform", "task time distribution: uniform, bimodal or peak-at-bottom")
+	cycle_time := fs.Float64("c", pwlb.GetTaskContainer().GetCycleTime(), "cycle time task times are drawn for")
+	seed := fs.Int64("seed", 1, "random seed, the same seed gives the same spec")
+	fs.Parse(args)
+
+	dist, err := pwlb.ParseTimeDistribution(*times)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	task_strings, err := pwlb.GenerateSpec(pwlb.GeneratorConfig{
+		NumTasks:      *num_tasks,
+		OrderStrength: *order_strength,
+		Times:         dist,
+		CycleTime:     *cycle_time,
+		Seed:          *seed,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if fs.NArg() != 0 {
+		pwlb.WriteSpecToPath(fs.Arg(0), task_strings)
+		return
+	}
+	fmt.Println(strings.Join(task_strings, "\n"))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"pwlb"
+	"strconv"
+)
+
+// Prints the earliest and latest station of every task. The station count of the
+// SST solution is used as the upper bound on stations.
+func runIntervals(args []string) {
+	fs, cycle_time := newSpecFlagSet("intervals")
+	fs.Parse(args)
+
+	tc := fillTaskContainer(fs.Args(), *cycle_time)
+	defer tc.Clear()
+	if err := tc.CheckPins(nil); err != nil {
+		log.Fatal(err)
+	}
+
+	wsc := pwlb.GetWorkstationContainer()
+	wsc.FillFrom(tc)
+	defer wsc.Clear()
+
+	upper_bound := computeSolution(context.Background(), pwlb.SolveSST, nil).GetSolution().GetMeasuredMin()
+	intervals := tc.GetStationIntervals(upper_bound)
+
+	num_fixed := 0
+	for i, _ := range intervals {
+		if intervals[i].IsFixed() {
+			num_fixed++
+		}
+	}
+
+	fmt.Println("theoretical_min=" + strconv.Itoa(pwlb.GetTheoreticalMin()))
+	fmt.Println("upper_bound=" + strconv.Itoa(upper_bound))
+	fmt.Println("fixed_tasks=" + strconv.Itoa(num_fixed))
+	fmt.Print("\n\n")
+
+	fmt.Print(pwlb.PrettyStationIntervalsStr(intervals))
+}
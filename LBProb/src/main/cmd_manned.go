@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"pwlb"
+	"strconv"
+)
+
+// Balances the spec with several operators working at once at each station and
+// prints the solution with the schedule of every operator
+func runManned(args []string) {
+	fs, cycle_time := newSpecFlagSet("manned")
+	max_operators := fs.Int("operators", 2, "most operators working at one station")
+	timeout := fs.Duration("timeout", 0, "time limit of the solve, 0 for none")
+	fs.Parse(args)
+
+	ctx, cancel := newSolveContext(*timeout)
+	defer cancel()
+
+	tc := fillTaskContainer(fs.Args(), *cycle_time)
+	defer tc.Clear()
+
+	result, err := pwlb.SolveMultiManned(ctx, tc, *max_operators)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sol := result.GetSolution()
+	if result.GetStatus() == pwlb.SolveInterrupted {
+		if sol.Validate() != nil {
+			fmt.Fprintln(os.Stderr, "WARNING Solve interrupted, not all tasks are assigned")
+		} else {
+			fmt.Fprintln(os.Stderr, "WARNING Solve interrupted, the solution may not be optimal")
+		}
+	}
+
+	wsc := pwlb.GetWorkstationContainer()
+	wsc.FillFrom(tc)
+	defer wsc.Clear()
+	wsc.FillFromSolution(sol)
+
+	fmt.Println("operator_lower_bound=" + strconv.Itoa(tc.GetLowerBound()))
+	fmt.Println("operators=" + strconv.Itoa(sol.GetOperatorCount()))
+	fmt.Println("measured_min=" + strconv.Itoa(sol.GetMeasuredMin()))
+	fmt.Println("line_efficiency=" + sol.GetLineEfficiencyStr())
+	fmt.Println("smoothness_index=" + sol.GetSmoothnessIndexStr())
+	fmt.Print("\n\n")
+
+	fmt.Print(pwlb.PrettySolutionStr(sol))
+	fmt.Print("\n\n")
+	fmt.Print(pwlb.PrettyScheduleStr(sol))
+}
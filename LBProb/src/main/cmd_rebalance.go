@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"pwlb"
+	"strconv"
+)
+
+// Rebalances a changed spec starting from the solution of the spec before it and
+// prints the new solution with every task that moved, was added or removed
+func runRebalance(args []string) {
+	fs, cycle_time := newSpecFlagSet("rebalance")
+	layout_name := fs.String("line", "straight", "line layout of both specs: straight or u")
+	save_path := fs.String("save", "", "file to write the new solution to")
+	fs.Parse(args)
+
+	if fs.NArg() != 3 {
+		log.Fatal("Usage: rebalance [flags] old_spec old_solution new_spec")
+	}
+	layout, err := pwlb.ParseLineLayout(*layout_name)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	old_tc := pwlb.NewTaskContainer()
+	old_tc.FillFrom(pwlb.ReadSpecFromPath(fs.Arg(0)))
+	old_tc.SetCycleTime(*cycle_time)
+	old_tc.SetLayout(layout)
+	old_sol := readSolution(old_tc, fs.Arg(1))
+
+	// The new spec goes in the global container so the solution can be printed
+	tc := fillTaskContainer(fs.Args()[2:], *cycle_time)
+	defer tc.Clear()
+	tc.SetLayout(layout)
+
+	result, err := pwlb.Rebalance(context.Background(), old_sol, tc, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sol := result.GetSolution()
+
+	wsc := pwlb.GetWorkstationContainer()
+	wsc.FillFrom(tc)
+	defer wsc.Clear()
+	wsc.FillFromSolution(sol)
+
+	fmt.Println("moved_tasks=" + strconv.Itoa(result.Count(pwlb.TaskMoved)))
+	fmt.Println("added_tasks=" + strconv.Itoa(result.Count(pwlb.TaskAdded)))
+	fmt.Println("removed_tasks=" + strconv.Itoa(result.Count(pwlb.TaskRemoved)))
+	fmt.Println("measured_min=" + strconv.Itoa(sol.GetMeasuredMin()))
+	fmt.Println("line_efficiency=" + sol.GetLineEfficiencyStr())
+	fmt.Println("smoothness_index=" + sol.GetSmoothnessIndexStr())
+	fmt.Print("\n\n")
+
+	fmt.Print(pwlb.PrettySolutionStr(sol))
+	fmt.Print("\n\n")
+	fmt.Print(pwlb.PrettyRebalanceStr(result))
+
+	if *save_path != "" {
+		writeSolution(*save_path, sol)
+	}
+}
+
+// Reads a solution of the tasks in the container from a file written with -save
+func readSolution(tc *pwlb.TaskContainer, solution_path string) *pwlb.PartialSolution {
+	sol, err := pwlb.ParseSolution(tc, pwlb.ReadSpecFromPath(solution_path))
+	if err != nil {
+		log.Fatal(err.Error() + " in " + solution_path)
+	}
+	return sol
+}
+
+// Writes a solution to a file, one station per line like "2:3 4" with the copies
+// of replicated stations and the sides tasks are done on
+func writeSolution(solution_path string, sol *pwlb.PartialSolution) {
+	pwlb.WriteSpecToPath(solution_path, sol.ToSolutionLines())
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"pwlb"
+	"strconv"
+)
+
+// Reports prereqs that are implied by other prereqs of the same task. If a second
+// path is given the spec is written there with the redundant prereqs removed.
+func runReduce(args []string) {
+	input_task_strings := readTaskStrings(args)
+
+	tc := pwlb.GetTaskContainer()
+	tc.FillFrom(input_task_strings)
+	defer tc.Clear()
+
+	redundant := tc.GetGraph().GetRedundantPrereqs()
+
+	fmt.Println("redundant_prereqs=" + strconv.Itoa(len(redundant)))
+	fmt.Print("\n\n")
+	fmt.Print(pwlb.PrettyRedundantPrereqsStr(redundant))
+
+	if len(args) > 1 {
+		pwlb.WriteSpecToPath(args[1], tc.ToReducedStrArr())
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"pwlb"
+	"strconv"
+)
+
+// Simulates a saved solution of a spec with varying task times and prints how
+// often each station overruns and is the bottleneck, with the throughput and
+// efficiency the line can be expected to reach
+func runSimulate(args []string) {
+	fs, cycle_time := newSpecFlagSet("simulate")
+	cycles := fs.Int("cycles", 10000, "number of units sent down the line")
+	seed := fs.Int64("seed", 1, "random seed, the same seed gives the same result")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		log.Fatal("Usage: simulate [flags] spec solution")
+	}
+
+	tc := fillTaskContainer(fs.Args()[:1], *cycle_time)
+	defer tc.Clear()
+	sol := readSolution(tc, fs.Arg(1))
+
+	sr, err := pwlb.Simulate(sol, pwlb.SimulationConfig{Cycles: *cycles, Seed: *seed})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("cycles=" + strconv.Itoa(sr.GetCycles()))
+	fmt.Println("mean_cycle_time=" + strconv.FormatFloat(sr.GetMeanCycleTime(), 'f', 2, 64))
+	fmt.Println("throughput=" + strconv.FormatFloat(sr.GetThroughput(), 'g', 4, 64))
+	fmt.Println("throughput_share=" + strconv.FormatFloat(sr.GetThroughputShare()*100.0, 'f', 1, 64) + "%")
+	fmt.Println("line_efficiency=" + sol.GetLineEfficiencyStr())
+	fmt.Println("simulated_efficiency=" + strconv.FormatFloat(sr.GetEfficiency()*100.0, 'f', 1, 64) + "%")
+	fmt.Print("\n\n")
+
+	fmt.Print(pwlb.PrettySimulationStr(sr))
+}
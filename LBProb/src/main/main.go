@@ -1,43 +1,236 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
 	"pwlb"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// Sub commands, each gets the program args that follow the command name
+var commands = map[string]func([]string){
+	"analyze":   runAnalyze,
+	"bench":     runBench,
+	"crew":      runCrew,
+	"diff":      runDiff,
+	"flow":      runFlow,
+	"generate":  runGenerate,
+	"intervals": runIntervals,
+	"manned":    runManned,
+	"rebalance": runRebalance,
+	"reduce":    runReduce,
+	"simulate":  runSimulate,
+}
+
 func main() {
 	prog_args := os.Args[1:]
 
-	// Read from file if there is an arg passed to program otherwise read from stdin
-	var input_task_strings []string
+	// Run a sub command if one is named, otherwise solve the given spec
 	if len(prog_args) != 0 {
-		specs_path := prog_args[0]
-		input_task_strings = pwlb.ReadSpecFromPath(specs_path)
-	} else {
-		input_task_strings = pwlb.ReadSpecFromStdin()
+		if cmd, ok := commands[prog_args[0]]; ok {
+			cmd(prog_args[1:])
+			return
+		}
 	}
 
-	// Create and initialize global task container
+	runSolve(prog_args)
+}
+
+// Read from file if there is an arg passed to program otherwise read from stdin
+func readTaskStrings(args []string) []string {
+	if len(args) != 0 {
+		specs_path := args[0]
+		return pwlb.ReadSpecFromPath(specs_path)
+	}
+	return pwlb.ReadSpecFromStdin()
+}
+
+// Makes the flag set of a command that reads a spec, including the cycle time
+// flag those commands share
+func newSpecFlagSet(name string) (*flag.FlagSet, *float64) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	cycle_time := fs.Float64("c", pwlb.GetTaskContainer().GetCycleTime(), "cycle time of each workstation")
+	return fs, cycle_time
+}
+
+// Fills the global task container from the spec named in args
+func fillTaskContainer(args []string, cycle_time float64) *pwlb.TaskContainer {
 	tc := pwlb.GetTaskContainer()
-	tc.FillFrom(input_task_strings)
+	tc.FillFrom(readTaskStrings(args))
+	tc.SetCycleTime(cycle_time)
+	return tc
+}
+
+// Solves the spec and exits with the reason when the solver can't, like a pin the
+// heuristic ran out of room to meet
+func computeSolution(ctx context.Context, solver pwlb.SolverFunc, opts *pwlb.SolveOptions) *pwlb.SolveResult {
+	defer func() {
+		if msg := recover(); msg != nil {
+			log.Fatal(msg)
+		}
+	}()
+	return pwlb.ComputeSolution(ctx, solver, opts)
+}
+
+// Finds the zones that changed the solution and exits with the reason when
+// solving without them panics, like computeSolution
+func getBindingZones(ctx context.Context, tc *pwlb.TaskContainer, solver pwlb.SolverFunc,
+	opts *pwlb.SolveOptions) ([]pwlb.Zone, error) {
+	defer func() {
+		if msg := recover(); msg != nil {
+			log.Fatal(msg)
+		}
+	}()
+	return tc.GetBindingZones(ctx, solver, opts)
+}
+
+// Returns a context that ends on interrupt (Ctrl-C) or after the timeout, if one
+// is given, so a long solve can be stopped with the best solution found so far
+func newSolveContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	if timeout <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+func runSolve(args []string) {
+	fs, cycle_time := newSpecFlagSet("stt")
+	timeout := fs.Duration("timeout", 0, "time limit of the solve, 0 for none")
+	solver_name := fs.String("solver", "sst", "solver to use, one of "+
+		strings.Join(pwlb.GetSolverNames(), ", "))
+	layout_name := fs.String("line", "straight", "line layout: straight, u or two-sided")
+	max_replicas := fs.Int("replicas", 1, "most parallel copies of a station for tasks over the cycle time")
+	replica_cost := fs.Float64("replica-cost", 1.0, "cost of each station copy beyond the first")
+	service_level := fs.Float64("service-level", 0, "chance each station finishes in the cycle time when task times vary, 0 for none")
+	save_path := fs.String("save", "", "file to write the solution to, read by rebalance and diff")
+	fs.Parse(args)
+
+	solver, err := pwlb.GetSolver(*solver_name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	layout, err := pwlb.ParseLineLayout(*layout_name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := pwlb.CheckServiceLevel(*service_level); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := newSolveContext(*timeout)
+	defer cancel()
+
+	// Create and initialize global task container
+	tc := fillTaskContainer(fs.Args(), *cycle_time)
+	tc.SetLayout(layout)
 	defer tc.Clear()
 
+	// Pins that can't be met and solvers that can't solve the spec are reported
+	// before any solver starts
+	opts := &pwlb.SolveOptions{MaxReplicas: *max_replicas, ReplicaCost: *replica_cost,
+		ServiceLevel: *service_level}
+	if err := tc.CheckPins(opts); err != nil {
+		log.Fatal(err)
+	}
+	if err := pwlb.CheckSolver(*solver_name, tc, opts); err != nil {
+		log.Fatal(err)
+	}
+
 	// Create and initialize global workstation container
 	wsc := pwlb.GetWorkstationContainer()
 	wsc.FillFrom(tc)
 	defer wsc.Clear()
 
-	// Perform task assignments using SST algorithm
-	sol := pwlb.ComputeSolutionSST()
+	// Perform task assignments, with the SST heuristic by default
+	progress := showProgress(opts)
+	result := computeSolution(ctx, solver, opts)
+	progress.clear()
+	sol := result.GetSolution()
+	if result.GetStatus() == pwlb.SolveInterrupted {
+		if sol.Validate() != nil {
+			fmt.Fprintln(os.Stderr, "WARNING Solve interrupted, not all tasks are assigned")
+		} else {
+			fmt.Fprintln(os.Stderr, "WARNING Solve interrupted, the solution may not be optimal")
+		}
+	}
 
 	// Report results
 	fmt.Println("theoretical_min=" + strconv.Itoa(pwlb.GetTheoreticalMin()))
 	fmt.Println("measured_min=" + strconv.Itoa(sol.GetMeasuredMin()))
 	fmt.Println("line_efficiency=" + sol.GetLineEfficiencyStr())
 	fmt.Println("smoothness_index=" + sol.GetSmoothnessIndexStr())
+	if *max_replicas > 1 {
+		fmt.Println("line_cost=" + sol.GetLineCostStr(*replica_cost))
+	}
+	if tc.HasSetups() {
+		fmt.Println("setup_time=" + strconv.FormatFloat(sol.GetSetupTime(), 'f', 2, 64))
+	}
+	if tc.HasCosts() {
+		cb := sol.GetCostBreakdown()
+		fmt.Println("total_cost=" + strconv.FormatFloat(cb.GetTotalCost(), 'f', 2, 64))
+		fmt.Println("station_cost=" + strconv.FormatFloat(cb.GetFixedCost(), 'f', 2, 64))
+		fmt.Println("equipment_cost=" + strconv.FormatFloat(cb.GetEquipmentCost(), 'f', 2, 64))
+		fmt.Println("duplicated_equipment_cost=" + strconv.FormatFloat(cb.GetDuplicatedCost(), 'f', 2, 64))
+	}
+	if tc.HasStochasticTimes() {
+		fmt.Println("line_completion=" + strconv.FormatFloat(sol.GetLineCompletion()*100.0, 'f', 1, 64) + "%")
+	}
 	fmt.Print("\n\n")
 
 	fmt.Print(pwlb.PrettySolutionStr(sol))
+
+	// On a mixed-model line also show what each model needs at each station
+	if len(tc.GetModels()) != 0 {
+		fmt.Print("\n\n")
+		fmt.Println("models=" + strconv.Itoa(len(tc.GetModels())))
+		fmt.Println("model_overruns=" + strconv.Itoa(len(sol.GetModelOverruns())))
+		fmt.Print("\n\n")
+		fmt.Print(pwlb.PrettyModelLoadsStr(sol))
+	}
+
+	// With zones also show where their tasks went and which of them changed the
+	// solution, found by solving again without them
+	if len(tc.GetZones()) != 0 {
+		fmt.Print("\n\n")
+		fmt.Println("zones=" + strconv.Itoa(len(tc.GetZones())))
+		free_opts := &pwlb.SolveOptions{MaxReplicas: *max_replicas, ReplicaCost: *replica_cost,
+			ServiceLevel: *service_level}
+		binding, err := getBindingZones(ctx, tc, solver, free_opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "WARNING "+err.Error()+", binding zones not known")
+		} else {
+			fmt.Println("binding_zones=" + strconv.Itoa(len(binding)))
+		}
+		fmt.Print("\n\n")
+		fmt.Print(pwlb.PrettyZonesStr(sol, binding))
+	}
+
+	// With task times that vary also show the chance each station finishes in time
+	if tc.HasStochasticTimes() {
+		fmt.Print("\n\n")
+		fmt.Print(pwlb.PrettyStationCompletionsStr(sol))
+	}
+
+	// With station or equipment costs also show what each station and each piece
+	// of equipment costs
+	if tc.HasCosts() {
+		fmt.Print("\n\n")
+		fmt.Print(pwlb.PrettyCostStr(sol.GetCostBreakdown()))
+	}
+
+	if *save_path != "" {
+		writeSolution(*save_path, sol)
+	}
 }
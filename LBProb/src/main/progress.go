@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"pwlb"
+	"strconv"
+	"time"
+)
+
+// Redraw the progress line no more often than this, except for new incumbents
+const k_progress_interval = 100 * time.Millisecond
+
+// A single line on stderr rewritten in place as a solve makes progress
+type progressLine struct {
+	out        *os.File
+	lastDraw   time.Time
+	drawn      bool
+	stations   string
+	smoothness string
+}
+
+// Sets the options to show a live progress line on stderr and returns the line,
+// or nil when stderr isn't a terminal so redirected output stays clean
+func showProgress(opts *pwlb.SolveOptions) *progressLine {
+	info, err := os.Stderr.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+
+	line := &progressLine{out: os.Stderr, stations: "-", smoothness: "-"}
+	opts.Observer = line.update
+	return line
+}
+
+// Redraws the line for the event. Iterations come as often as branch and bound
+// opens a station, so they are throttled before anything else is done, and the
+// incumbent is only measured when it changes.
+func (line *progressLine) update(ev *pwlb.SolveEvent) {
+	now := time.Now()
+	switch ev.GetKind() {
+	case pwlb.EventIteration:
+		if now.Sub(line.lastDraw) < k_progress_interval {
+			return
+		}
+	case pwlb.EventIncumbent:
+		if sol := ev.GetIncumbent(); sol != nil {
+			line.stations = strconv.Itoa(sol.GetMeasuredMin())
+			line.smoothness = sol.GetSmoothnessIndexStr()
+		}
+	}
+	line.lastDraw = now
+	line.drawn = true
+
+	fmt.Fprintf(line.out, "\r\033[Kstations=%s lower_bound=%d smoothness_index=%s iterations=%d elapsed=%s",
+		line.stations, ev.GetLowerBound(), line.smoothness, ev.GetIterations(),
+		ev.GetElapsed().Round(time.Millisecond))
+}
+
+// Erases the progress line so the report starts on a clean line
+func (line *progressLine) clear() {
+	if line != nil && line.drawn {
+		fmt.Fprint(line.out, "\r\033[K")
+	}
+}
@@ -0,0 +1,263 @@
+package pwlb
+
+import (
+	"math"
+	"strconv"
+)
+
+// Tolerance used when converting summed task times into a station count, keeps
+// float error like 50.000000001 from costing a whole extra station
+const k_time_epsilon = 1e-9
+
+// Returns the least number of stations that can hold the given amount of task time
+func (tc *TaskContainer) stationsNeeded(task_time float64) int {
+	return int(math.Ceil(task_time/tc.cycleTime - k_time_epsilon))
+}
+
+///////////////////////////////////
+// Lower bounds
+///////////////////////////////////
+
+// Returns the best of the simple lower bounds on the number of stations. Besides
+// the total task time over the cycle time, tasks longer than half the cycle time
+// can't share a station, nor can more than two tasks longer than a third of it.
+func (tc *TaskContainer) GetLowerBound() int {
+	if tc.layout == LayoutTwoSided {
+		return tc.twoSidedLowerBound()
+	}
+
+	c := tc.cycleTime
+	task_time_sum := 0.0
+	halves := 0.0
+	thirds := 0.0
+	for i, _ := range tc.tasks {
+		t := tc.tasks[i].cost
+		task_time_sum += t
+
+		if t > c/2.0+k_time_epsilon {
+			halves += 1.0
+		} else if t >= c/2.0-k_time_epsilon {
+			halves += 0.5
+		}
+
+		if t > 2.0*c/3.0+k_time_epsilon {
+			thirds += 1.0
+		} else if t >= 2.0*c/3.0-k_time_epsilon {
+			thirds += 2.0 / 3.0
+		} else if t > c/3.0+k_time_epsilon {
+			thirds += 0.5
+		} else if t >= c/3.0-k_time_epsilon {
+			thirds += 1.0 / 3.0
+		}
+	}
+
+	lower_bound := tc.stationsNeeded(task_time_sum)
+	if lb := int(math.Ceil(halves - k_time_epsilon)); lb > lower_bound {
+		lower_bound = lb
+	}
+	if lb := int(math.Ceil(thirds - k_time_epsilon)); lb > lower_bound {
+		lower_bound = lb
+	}
+	return lower_bound
+}
+
+///////////////////////////////////
+// Station intervals
+///////////////////////////////////
+
+// The range of workstations a task may be assigned to in any solution that
+// uses no more than some upper bound of workstations
+type StationInterval struct {
+	task     TaskId_t
+	earliest WorkstationId_t
+	latest   WorkstationId_t
+}
+
+func (si *StationInterval) GetTaskId() TaskId_t {
+	return si.task
+}
+
+func (si *StationInterval) GetEarliest() WorkstationId_t {
+	return si.earliest
+}
+
+func (si *StationInterval) GetLatest() WorkstationId_t {
+	return si.latest
+}
+
+// Returns how many stations past the earliest one the task may be placed in,
+// a negative slack means the upper bound used is too small for the task
+func (si *StationInterval) Slack() int {
+	return int(si.latest - si.earliest)
+}
+
+// A task is fixed when there is exactly one station it can go in
+func (si *StationInterval) IsFixed() bool {
+	return si.earliest == si.latest
+}
+
+// Returns an interval as a string like "4 [1,3]"
+func (si *StationInterval) ToStr() string {
+	str := strconv.Itoa(int(si.task)) + " ["
+	str += strconv.Itoa(int(si.earliest)) + "," + strconv.Itoa(int(si.latest)) + "]"
+	return str
+}
+
+// Computes the earliest and latest station of every task given an upper bound on
+// the number of stations. The earliest station follows from the time of the task
+// and all of its predecessors, the latest from the time of the task and all of its
+// followers. Pins narrow the intervals of the pinned tasks and of the tasks before
+// and after them. Intervals are returned in the same order as the tasks in the
+// container
+func (tc *TaskContainer) GetStationIntervals(upper_bound int) []StationInterval {
+	var intervals []StationInterval
+	if len(tc.tasks) == 0 {
+		return intervals
+	}
+
+	// Workstation ids start at the id of the first task
+	ws_start_id := WorkstationId_t(tc.tasks[0].id)
+
+	graph := tc.GetGraph()
+	pins, _ := tc.getPinWindows(nil)
+
+	for i, _ := range tc.tasks {
+		head_time := tc.tasks[i].cost + graph.PredecessorsTime(tc.tasks[i].id)
+		tail_time := tc.tasks[i].cost + graph.FollowersTime(tc.tasks[i].id)
+
+		// Station numbers below are counted from 1
+		earliest := tc.stationsNeeded(head_time)
+		latest := upper_bound + 1 - tc.stationsNeeded(tail_time)
+
+		interval := StationInterval{
+			task:     tc.tasks[i].id,
+			earliest: ws_start_id + WorkstationId_t(earliest-1),
+			latest:   ws_start_id + WorkstationId_t(latest-1),
+		}
+		if pins != nil && pins.release[i] > interval.earliest {
+			interval.earliest = pins.release[i]
+		}
+		if pins != nil && pins.deadline[i] < interval.latest {
+			interval.latest = pins.deadline[i]
+		}
+		intervals = append(intervals, interval)
+	}
+
+	return intervals
+}
+
+func PrettyStationIntervalsStr(intervals []StationInterval) string {
+	str := ""
+	for i, _ := range intervals {
+		si := &intervals[i]
+		str += "Task " + strconv.Itoa(int(si.task)) + ":      "
+		str += "Earliest " + strconv.Itoa(int(si.earliest))
+		str += "   Latest " + strconv.Itoa(int(si.latest))
+		str += "   Slack " + strconv.Itoa(si.Slack())
+		if si.IsFixed() {
+			str += "   fixed"
+		} else if si.Slack() < 0 {
+			str += "   infeasible"
+		}
+		str += "\n"
+	}
+	return str
+}
+
+///////////////////////////////////
+// Precedence graph metrics
+///////////////////////////////////
+
+// Measures used to characterize a problem instance before picking a solver
+type GraphMetrics struct {
+	numTasks      int
+	numRelations  int // direct prereq links listed in the spec
+	orderStrength float64
+	westRatio     float64
+	depth         int
+	criticalTime  float64
+	criticalPath  []TaskId_t
+}
+
+func (gm *GraphMetrics) GetNumTasks() int {
+	return gm.numTasks
+}
+
+func (gm *GraphMetrics) GetNumRelations() int {
+	return gm.numRelations
+}
+
+// Share of all task pairs that are ordered by precedence, directly or not
+func (gm *GraphMetrics) GetOrderStrength() float64 {
+	return gm.orderStrength
+}
+
+// Share of all task pairs that may be done in either order
+func (gm *GraphMetrics) GetFlexibilityRatio() float64 {
+	return 1.0 - gm.orderStrength
+}
+
+// Average number of tasks per station at the theoretical min
+func (gm *GraphMetrics) GetWestRatio() float64 {
+	return gm.westRatio
+}
+
+// Number of edges on the longest chain of prereqs
+func (gm *GraphMetrics) GetDepth() int {
+	return gm.depth
+}
+
+// Sum of task times on the most time consuming chain of prereqs
+func (gm *GraphMetrics) GetCriticalPathTime() float64 {
+	return gm.criticalTime
+}
+
+func (gm *GraphMetrics) GetCriticalPath() []TaskId_t {
+	return gm.criticalPath
+}
+
+func (tc *TaskContainer) GetGraphMetrics() *GraphMetrics {
+	gm := &GraphMetrics{numTasks: len(tc.tasks)}
+	if gm.numTasks == 0 {
+		return gm
+	}
+
+	graph := tc.GetGraph()
+
+	// Every pair of tasks related through the transitive closure counts once
+	num_ordered_pairs := graph.NumOrderedPairs()
+	for i, _ := range tc.tasks {
+		gm.numRelations += len(tc.tasks[i].prereqs)
+	}
+
+	if gm.numTasks > 1 {
+		num_pairs := gm.numTasks * (gm.numTasks - 1) / 2
+		gm.orderStrength = float64(num_ordered_pairs) / float64(num_pairs)
+	}
+
+	task_time_sum := 0.0
+	for i, _ := range tc.tasks {
+		task_time_sum += tc.tasks[i].cost
+	}
+	if min := tc.stationsNeeded(task_time_sum); min > 0 {
+		gm.westRatio = float64(gm.numTasks) / float64(min)
+	}
+
+	gm.depth, _ = graph.MaxHeightWithPath()
+	gm.criticalTime, gm.criticalPath = graph.CriticalPath()
+
+	return gm
+}
+
+// Returns the metrics as lines like "order_strength=22.4%"
+func PrettyGraphMetricsStr(gm *GraphMetrics) string {
+	str := "num_tasks=" + strconv.Itoa(gm.numTasks) + "\n"
+	str += "num_relations=" + strconv.Itoa(gm.numRelations) + "\n"
+	str += "order_strength=" + strconv.FormatFloat(gm.orderStrength*100.0, 'f', 1, 64) + "%\n"
+	str += "flexibility_ratio=" + strconv.FormatFloat(gm.GetFlexibilityRatio()*100.0, 'f', 1, 64) + "%\n"
+	str += "west_ratio=" + strconv.FormatFloat(gm.westRatio, 'f', 2, 64) + "\n"
+	str += "graph_depth=" + strconv.Itoa(gm.depth) + "\n"
+	str += "critical_path_time=" + strconv.FormatFloat(gm.criticalTime, 'f', 1, 64) + "\n"
+	str += "critical_path=" + taskIdsToStr(gm.criticalPath) + "\n"
+	return str
+}
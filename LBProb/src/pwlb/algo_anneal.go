@@ -0,0 +1,203 @@
+package pwlb
+
+import (
+	"context"
+	"math"
+	"math/rand"
+)
+
+// Steps of simulated annealing, fewer on large specs, see searchSteps
+const k_anneal_steps = 2000
+
+// Temperature of the first and last step of simulated annealing, in the units of
+// priorityScore where a station is one
+const k_anneal_start_temperature = 0.5
+const k_anneal_end_temperature = 0.001
+
+// Every search over priorities starts from the same seed so a solve is repeatable
+const k_priority_seed = 1
+
+// Task placements a search over priorities may spend, the steps are cut down so
+// a large spec takes about as long as this many
+const k_priority_work = 2000000
+
+///////////////////////////////////
+// Priority decoding
+///////////////////////////////////
+
+// Decodes priorities of the tasks, by container index, into solutions by filling
+// stations like RPW with the available task of the highest priority that fits
+// first. Simulated annealing and the genetic algorithm search over priorities.
+type priorityDecoder struct {
+	tc   *TaskContainer
+	opts *SolveOptions // the options of the solve without the observer or shared bounds
+}
+
+func newPriorityDecoder(tc *TaskContainer, opts *SolveOptions) *priorityDecoder {
+	pd := &priorityDecoder{tc: tc, opts: &SolveOptions{}}
+	if opts != nil {
+		pd.opts.MaxReplicas = opts.MaxReplicas
+		pd.opts.ReplicaCost = opts.ReplicaCost
+		pd.opts.ServiceLevel = opts.ServiceLevel
+	}
+	return pd
+}
+
+// Returns the RPW positional weights scaled to at most one, the priorities the
+// searches start from
+func (pd *priorityDecoder) weights() []float64 {
+	priorities := rpwWeights(pd.tc)
+	max_weight := 0.0
+	for _, weight := range priorities {
+		max_weight = math.Max(max_weight, weight)
+	}
+	if max_weight > 0.0 {
+		for i, _ := range priorities {
+			priorities[i] /= max_weight
+		}
+	}
+	return priorities
+}
+
+// Returns the solution the priorities give, nil if the context ended first. Ties
+// go to the task listed first. Panics like the solvers when the tasks can't be
+// assigned.
+func (pd *priorityDecoder) decode(ctx context.Context, priorities []float64) *PartialSolution {
+	less := func(lhs, rhs int) bool {
+		if priorities[lhs] != priorities[rhs] {
+			return priorities[lhs] > priorities[rhs]
+		}
+		return lhs < rhs
+	}
+	result := solveStationOriented(ctx, pd.tc, pd.opts, less, false)
+	if result.status == SolveInterrupted {
+		return nil
+	}
+	return result.sol
+}
+
+// Like decode but also returns nil for priorities that leave a task no station,
+// like one that runs out of room to meet a pin, so a search can skip them
+func (pd *priorityDecoder) tryDecode(ctx context.Context, priorities []float64) (sol *PartialSolution) {
+	defer func() {
+		if recover() != nil {
+			sol = nil
+		}
+	}()
+	return pd.decode(ctx, priorities)
+}
+
+// Returns the number of steps of a search that would take the given number of
+// steps on a small spec, fewer on a large one but never less than a tenth
+func (pd *priorityDecoder) searchSteps(steps int) int {
+	if num_tasks := len(pd.tc.tasks); num_tasks*steps > k_priority_work {
+		return int(math.Max(float64(k_priority_work/num_tasks), float64(steps/10)))
+	}
+	return steps
+}
+
+// Returns the score of a solution, lower is better. It is the number of stations
+// less half the mean squared use of them, so of two solutions with as many
+// stations the one with fuller stations and an emptier last one scores better.
+func priorityScore(sol *PartialSolution) float64 {
+	tc := sol.getTaskContainer()
+	loads := sol.getOperatorLoads()
+	use := 0.0
+	for key, load := range loads {
+		station_use := load / (tc.cycleTime * float64(sol.GetReplicas(key.ws)))
+		use += station_use * station_use
+	}
+	if len(loads) != 0 {
+		use /= float64(len(loads))
+	}
+	return float64(sol.GetMeasuredMin()) - 0.5*math.Min(use, 1.0)
+}
+
+// Returns true when the search should stop, the context ended or a solution
+// known to be optimal was found by it or by another solver of a portfolio
+func priorityDone(ctx context.Context, progress *solveProgress, best *PartialSolution) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	if best != nil && best.GetMeasuredMin() <= progress.state.lowerBound {
+		return true
+	}
+	_, _, optimal := progress.shared.get()
+	return optimal
+}
+
+///////////////////////////////////
+// Simulated annealing
+///////////////////////////////////
+
+// Assigns the tasks in the container to stations with simulated annealing over
+// the priorities of the tasks, each decoded into a solution by filling stations
+// like RPW with the available task of the highest priority first. The search
+// starts from the positional weights, so from the RPW solution, and each step
+// either swaps the priorities of two tasks or gives one task a new random
+// priority. A step to a solution scoring worse, see priorityScore, is kept with a
+// chance that falls as the temperature is lowered from step to step. The best
+// solution seen is returned. The global containers are not used.
+//
+// The same seed is used on every solve so the result is repeatable. The search
+// ends early when the best solution meets the lower bound or, in a portfolio,
+// another solver finds one known to be optimal. If the context ends first the
+// best solution found so far is returned as interrupted. Everything the station
+// oriented solvers handle, like pins, zones and replicas, is kept by decoding.
+// An observer gets an iteration for every step and a solution for every better
+// one found.
+func SolveAnnealing(ctx context.Context, tc *TaskContainer, opts *SolveOptions) *SolveResult {
+	if tc.hasTogetherZones() {
+		return solveZoned(ctx, tc, opts, SolveAnnealing)
+	}
+	if len(tc.tasks) == 0 {
+		return newCompletedResult(&PartialSolution{tc: tc})
+	}
+
+	progress := newSolveProgress(opts)
+	progress.bound(tc.GetLowerBound())
+
+	pd := newPriorityDecoder(tc, opts)
+	rng := rand.New(rand.NewSource(k_priority_seed))
+	current := pd.weights()
+	best := pd.decode(ctx, current)
+	if best == nil {
+		return &SolveResult{&PartialSolution{tc: tc}, SolveInterrupted}
+	}
+	progress.incumbent(best)
+	current_score := priorityScore(best)
+	best_score := current_score
+
+	steps := pd.searchSteps(k_anneal_steps)
+	cooling := math.Pow(k_anneal_end_temperature/k_anneal_start_temperature, 1.0/float64(steps))
+	temperature := k_anneal_start_temperature
+	num_tasks := len(tc.tasks)
+	for step := 0; step < steps && !priorityDone(ctx, progress, best); step++ {
+		next := append([]float64(nil), current...)
+		if i, j := rng.Intn(num_tasks), rng.Intn(num_tasks); rng.Intn(2) == 0 && i != j {
+			next[i], next[j] = next[j], next[i]
+		} else {
+			next[i] = rng.Float64()
+		}
+		temperature *= cooling
+		progress.iteration()
+
+		sol := pd.tryDecode(ctx, next)
+		if sol == nil {
+			continue
+		}
+		score := priorityScore(sol)
+		if score < current_score || rng.Float64() < math.Exp((current_score-score)/temperature) {
+			current, current_score = next, score
+		}
+		if score < best_score {
+			best, best_score = sol, score
+			progress.incumbent(best)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return &SolveResult{best, SolveInterrupted}
+	}
+	return newCompletedResult(best)
+}
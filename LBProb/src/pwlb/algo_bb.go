@@ -0,0 +1,327 @@
+package pwlb
+
+import (
+	"context"
+	"math"
+)
+
+// Check the context once per this many search nodes
+const k_bb_check_interval = 256
+
+// State of a depth first branch and bound over station loads
+type bbSearch struct {
+	ctx      context.Context
+	tc       *TaskContainer
+	graph    *TaskGraph
+	progress *solveProgress
+
+	firstWsId   WorkstationId_t
+	lowerBound  int
+	indegree    []int // unassigned prereqs of each task
+	outdegree   []int // unassigned postreqs of each task, only kept on a U-line
+	assigned    []bool
+	back        []bool // done on the back side of a U-line
+	station     []int  // station of each assigned task, counted from zero
+	apart       [][]int
+	pins        *pinWindows
+	serviceZ    float64 // standard deviations a load may take over its mean, see getServiceZ
+	setups      bool    // the tasks have setup times, loads include them
+	stack       []TaskAssignment
+	timeLeft    float64 // total time of the unassigned tasks
+	best        *PartialSolution
+	bestCount   int // stations of the best solution
+	numNodes    int
+	interrupted bool
+}
+
+// Finds a solution with the fewest stations by searching over station loads. Each
+// station in turn is given every maximal load, a set of available tasks that fits
+// the cycle time and that no other available task can be added to, and a branch
+// is cut as soon as the stations used plus the time bound on the tasks left can't
+// beat the best solution known. Loads are built in topological order of their
+// tasks so each is tried once. The global containers are not used.
+//
+// On a U-line a task is also available once its postreqs are assigned. A load
+// may then be reached in more than one order, so loads are built from tasks in
+// any order and the same load may be tried more than once.
+//
+// Tasks zoned together are searched as one task, see solveZoned, and a load
+// never holds two tasks zoned apart. Loads only hold tasks their pins allow at
+// the station and a branch is cut once a task can no longer meet its pin. At a
+// service level a load must meet it like it must fit the cycle time. With setup
+// times a station does its tasks in the order they were added to its load and
+// its setups count towards the cycle time, other orders aren't tried so the
+// search may then miss the optimum.
+//
+// The search ends early when the best solution meets the lower bound. When it
+// runs to the end the best solution is optimal. In a portfolio the best solution
+// of every solver is used to cut branches, so the search may end without finding
+// a solution of its own, having shown the one found elsewhere is optimal. If the
+// context ends first the best solution found so far is returned, which may have
+// no assignments.
+func SolveBranchAndBound(ctx context.Context, tc *TaskContainer, opts *SolveOptions) *SolveResult {
+	if len(tc.tasks) == 0 {
+		return newCompletedResult(&PartialSolution{tc: tc})
+	}
+	if tc.hasTogetherZones() {
+		return solveZoned(ctx, tc, opts, SolveBranchAndBound)
+	}
+
+	if tc.layout == LayoutTwoSided {
+		panic("Branch and bound doesn't solve two-sided lines")
+	}
+
+	z := opts.getServiceZ()
+	for i, _ := range tc.tasks {
+		if stationTimeNeeded(tc.tasks[i].cost, tc.tasks[i].sd*tc.tasks[i].sd, z) > tc.cycleTime+k_time_epsilon {
+			panic(tc.unfitTaskStr(i, 1, opts))
+		}
+	}
+
+	pins, err := tc.getPinWindows(opts)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	bb := &bbSearch{
+		ctx:        ctx,
+		tc:         tc,
+		graph:      tc.GetGraph(),
+		progress:   newSolveProgress(opts),
+		firstWsId:  WorkstationId_t(tc.tasks[0].id),
+		lowerBound: tc.GetLowerBound(),
+		indegree:   make([]int, len(tc.tasks)),
+		outdegree:  make([]int, len(tc.tasks)),
+		assigned:   make([]bool, len(tc.tasks)),
+		back:       make([]bool, len(tc.tasks)),
+		station:    make([]int, len(tc.tasks)),
+		apart:      tc.apartIdxs(),
+		pins:       pins,
+		serviceZ:   z,
+		setups:     tc.HasSetups(),
+		stack:      make([]TaskAssignment, 0, len(tc.tasks)),
+	}
+	for i, _ := range tc.tasks {
+		bb.indegree[i] = len(bb.graph.prereqs[i])
+		if tc.layout == LayoutU {
+			bb.outdegree[i] = len(bb.graph.postreqs[i])
+		} else {
+			bb.outdegree[i] = -1
+		}
+		bb.timeLeft += tc.tasks[i].cost
+	}
+	bb.progress.bound(bb.lowerBound)
+
+	bb.searchStation(0)
+
+	if bb.interrupted {
+		if bb.best == nil {
+			return &SolveResult{&PartialSolution{tc: tc}, SolveInterrupted}
+		}
+		return &SolveResult{bb.best, SolveInterrupted}
+	}
+
+	// Other sequences of the stations may do better with setup times
+	if !bb.setups {
+		bb.progress.shared.prove()
+	}
+	if bb.best == nil || bb.bestCount > bb.bestStations() {
+		// Only pins can leave the whole search without a solution
+		if stations, _, _ := bb.progress.shared.get(); stations == 0 {
+			panic("No solution meets the pins of the tasks")
+		}
+		return &SolveResult{&PartialSolution{tc: tc}, SolveCompleted}
+	}
+	if bb.setups {
+		return &SolveResult{bb.best, SolveCompleted}
+	}
+	return &SolveResult{bb.best, SolveOptimal}
+}
+
+// Returns the fewest stations of any solution known, also those found by other
+// solvers of a portfolio, or the max int if there is none yet
+func (bb *bbSearch) bestStations() int {
+	stations, _, _ := bb.progress.shared.get()
+	if bb.best != nil && (stations == 0 || bb.bestCount < stations) {
+		stations = bb.bestCount
+	}
+	if stations == 0 {
+		return math.MaxInt32
+	}
+	return stations
+}
+
+// Returns true when the search should stop, because the context ended or the best
+// solution meets the lower bound
+func (bb *bbSearch) stopped() bool {
+	if bb.interrupted {
+		return true
+	}
+	bb.numNodes++
+	if bb.numNodes%k_bb_check_interval == 0 && bb.ctx.Err() != nil {
+		bb.interrupted = true
+		return true
+	}
+
+	_, shared_bound, _ := bb.progress.shared.get()
+	if shared_bound < bb.lowerBound {
+		shared_bound = bb.lowerBound
+	}
+	return bb.bestStations() <= shared_bound
+}
+
+// Opens a new station after num_stations are filled
+func (bb *bbSearch) searchStation(num_stations int) {
+	if bb.stopped() {
+		return
+	}
+	bb.progress.iteration()
+
+	if len(bb.stack) == len(bb.tc.tasks) {
+		if num_stations < bb.bestStations() {
+			assignments := make([]TaskAssignment, len(bb.stack))
+			copy(assignments, bb.stack)
+			bb.best = &PartialSolution{assignments: assignments, tc: bb.tc}
+			if bb.tc.layout == LayoutU {
+				bb.best.back = make(map[TaskId_t]bool)
+				for i, _ := range bb.tc.tasks {
+					if bb.back[i] {
+						bb.best.back[bb.tc.tasks[i].id] = true
+					}
+				}
+			}
+			bb.bestCount = num_stations
+			bb.progress.incumbent(bb.best)
+		}
+		return
+	}
+
+	if num_stations+bb.tc.stationsNeeded(bb.timeLeft) >= bb.bestStations() {
+		return
+	}
+
+	// A task that can't go after the last station closed is left out for good
+	if bb.pins != nil {
+		for i, _ := range bb.tc.tasks {
+			if !bb.assigned[i] && bb.pins.deadline[i] < bb.firstWsId+WorkstationId_t(num_stations) {
+				return
+			}
+		}
+	}
+	bb.searchLoad(num_stations, 0, 0.0, 0.0)
+}
+
+// Adds tasks to the load of the open station, only tasks after position min_pos
+// of the topological order, and closes the station once no available task fits.
+// The variance of the load is kept for the service level.
+func (bb *bbSearch) searchLoad(num_stations, min_pos int, load, variance float64) {
+	free := bb.tc.cycleTime - load
+	maximal := true
+	for pos, idx := range bb.graph.topoOrder {
+		if bb.assigned[idx] || (bb.indegree[idx] != 0 && bb.outdegree[idx] != 0) ||
+			bb.tc.tasks[idx].cost > free || bb.conflicts(idx, num_stations) ||
+			!bb.pins.allows(idx, bb.firstWsId+WorkstationId_t(num_stations)) {
+			continue
+		}
+		setup := bb.setupAdded(idx, num_stations)
+		if bb.tc.tasks[idx].cost+setup > free || !bb.meetsServiceLevel(idx, load+setup, variance) {
+			continue
+		}
+		maximal = false
+		if pos < min_pos {
+			continue
+		}
+
+		next_pos := pos + 1
+		if bb.tc.layout == LayoutU {
+			next_pos = 0
+		}
+		bb.assign(idx, num_stations)
+		sd := bb.tc.tasks[idx].sd
+		bb.searchLoad(num_stations, next_pos, load+bb.tc.tasks[idx].cost+setup, variance+sd*sd)
+		bb.unassign(idx)
+
+		if bb.stopped() {
+			return
+		}
+	}
+
+	// A station pins leave empty can't be part of a solution
+	if maximal && len(bb.stack) != 0 && bb.stack[len(bb.stack)-1].j == bb.firstWsId+WorkstationId_t(num_stations) {
+		bb.searchStation(num_stations + 1)
+	}
+}
+
+// Returns true if the open station still meets the service level with the task
+func (bb *bbSearch) meetsServiceLevel(idx int, load, variance float64) bool {
+	if bb.serviceZ == 0.0 {
+		return true
+	}
+	sd := bb.tc.tasks[idx].sd
+	return stationTimeNeeded(load+bb.tc.tasks[idx].cost, variance+sd*sd, bb.serviceZ) <= bb.tc.cycleTime+k_time_epsilon
+}
+
+// Returns the setup time the task adds when it is done last at the open station,
+// after the task before it and before the first task of the next unit
+func (bb *bbSearch) setupAdded(idx, num_stations int) float64 {
+	wsid := bb.firstWsId + WorkstationId_t(num_stations)
+	last := len(bb.stack) - 1
+	if !bb.setups || last < 0 || bb.stack[last].j != wsid {
+		return 0.0
+	}
+	first := last
+	for first > 0 && bb.stack[first-1].j == wsid {
+		first--
+	}
+
+	id, last_id, first_id := bb.tc.tasks[idx].id, bb.stack[last].i, bb.stack[first].i
+	added := bb.tc.GetSetupTime(last_id, id) + bb.tc.GetSetupTime(id, first_id)
+	if first != last {
+		added -= bb.tc.GetSetupTime(last_id, first_id)
+	}
+	return added
+}
+
+// Returns true if a task zoned apart from the task is at the open station
+func (bb *bbSearch) conflicts(idx, num_stations int) bool {
+	if bb.apart == nil {
+		return false
+	}
+	for _, other := range bb.apart[idx] {
+		if bb.assigned[other] && bb.station[other] == num_stations {
+			return true
+		}
+	}
+	return false
+}
+
+func (bb *bbSearch) assign(idx, num_stations int) {
+	bb.assigned[idx] = true
+	bb.station[idx] = num_stations
+	bb.back[idx] = bb.indegree[idx] != 0
+	bb.timeLeft -= bb.tc.tasks[idx].cost
+	bb.stack = append(bb.stack, TaskAssignment{bb.tc.tasks[idx].id,
+		bb.firstWsId + WorkstationId_t(num_stations)})
+	for _, post_idx := range bb.graph.postreqs[idx] {
+		bb.indegree[post_idx]--
+	}
+	if bb.tc.layout == LayoutU {
+		for _, prereq_idx := range bb.graph.prereqs[idx] {
+			bb.outdegree[prereq_idx]--
+		}
+	}
+}
+
+func (bb *bbSearch) unassign(idx int) {
+	if bb.tc.layout == LayoutU {
+		for _, prereq_idx := range bb.graph.prereqs[idx] {
+			bb.outdegree[prereq_idx]++
+		}
+	}
+	for _, post_idx := range bb.graph.postreqs[idx] {
+		bb.indegree[post_idx]++
+	}
+	bb.stack = bb.stack[:len(bb.stack)-1]
+	bb.timeLeft += bb.tc.tasks[idx].cost
+	bb.assigned[idx] = false
+}
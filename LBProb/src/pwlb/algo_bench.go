@@ -0,0 +1,298 @@
+package pwlb
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Settings for running solvers over every spec in a directory
+type BenchConfig struct {
+	SpecDir    string
+	Solvers    []string
+	CycleTime  float64
+	Timeout    time.Duration // per solver and spec, no limit when zero
+	OptimaPath string        // optional file of known optima
+	Layout     LineLayout
+}
+
+// Outcome of one solver on one spec
+type BenchResult struct {
+	spec       string
+	solver     string
+	cycleTime  float64
+	numTasks   int
+	lowerBound int
+	optimum    int // zero when not known
+	stations   int
+	efficiency float64
+	smoothness float64
+	wallTime   time.Duration
+	status     string // "optimal", "ok", "timeout", "invalid" or "failed"
+}
+
+func (br *BenchResult) GetStatus() string {
+	return br.status
+}
+
+func (br *BenchResult) GetStations() int {
+	return br.stations
+}
+
+// Runs every solver named in the config on every spec in the directory, in name
+// order. Files ending in .txt are read as specs and files ending in .IN2 in the
+// format of the Scholl data sets, anything else is skipped. Each solve gets its
+// own task container and a context ending at the timeout.
+func RunBench(cfg BenchConfig) ([]BenchResult, error) {
+	var solver_funcs []SolverFunc
+	for _, name := range cfg.Solvers {
+		solver, err := GetSolver(name)
+		if err != nil {
+			return nil, err
+		}
+		solver_funcs = append(solver_funcs, solver)
+	}
+
+	optima := make(map[string]int)
+	if cfg.OptimaPath != "" {
+		var err error
+		if optima, err = readKnownOptima(cfg.OptimaPath); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := ioutil.ReadDir(cfg.SpecDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []BenchResult
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		var task_strings []string
+		spec_path := filepath.Join(cfg.SpecDir, entry.Name())
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".txt":
+			task_strings = ReadSpecFromPath(spec_path)
+		case ".in2":
+			task_strings = ReadSchollSpecFromPath(spec_path)
+		default:
+			continue
+		}
+
+		for i, solver := range solver_funcs {
+			tc := NewTaskContainer()
+			tc.FillFrom(task_strings)
+			tc.SetCycleTime(cfg.CycleTime)
+			tc.SetLayout(cfg.Layout)
+
+			result := BenchResult{
+				spec:      entry.Name(),
+				solver:    cfg.Solvers[i],
+				cycleTime: cfg.CycleTime,
+				numTasks:  len(tc.tasks),
+				optimum:   optima[optimaKey(entry.Name(), cfg.CycleTime)],
+			}
+
+			// A spec whose prereqs form no graph fails like a solver that panics
+			if tc.CheckGraph() != nil {
+				result.status = "failed"
+				results = append(results, result)
+				continue
+			}
+			result.lowerBound = tc.GetLowerBound()
+			runBenchSolve(&result, solver, tc, cfg.Timeout)
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+func runBenchSolve(result *BenchResult, solver SolverFunc, tc *TaskContainer, timeout time.Duration) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// A solver panics on a task that can never fit a station
+	start := time.Now()
+	defer func() {
+		if err := recover(); err != nil {
+			result.wallTime = time.Since(start)
+			result.status = "failed"
+		}
+	}()
+
+	solve_result := solver(ctx, tc, nil)
+	result.wallTime = time.Since(start)
+
+	// A solver stopped at the timeout may still have a complete solution to report
+	sol := solve_result.GetSolution()
+	switch {
+	case solve_result.GetStatus() == SolveInterrupted:
+		result.status = "timeout"
+		if sol.Validate() != nil {
+			return
+		}
+	case sol.Validate() != nil:
+		result.status = "invalid"
+	case solve_result.GetStatus() == SolveOptimal:
+		result.status = "optimal"
+	default:
+		result.status = "ok"
+	}
+
+	result.stations = sol.GetMeasuredMin()
+	result.efficiency = sol.GetLineEfficiency()
+	result.smoothness = sol.GetSmoothnessIndex()
+}
+
+func optimaKey(spec_name string, cycle_time float64) string {
+	return spec_name + "@" + costToStr(cycle_time)
+}
+
+// Reads lines like "spec5.txt,50,8" giving the optimal number of stations of a
+// spec at a cycle time. Blank lines and lines starting with # are skipped.
+func readKnownOptima(optima_path string) (map[string]int, error) {
+	optima_file, err := os.Open(optima_path)
+	if err != nil {
+		return nil, err
+	}
+	defer optima_file.Close()
+
+	optima := make(map[string]int)
+	scanner := bufio.NewScanner(optima_file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, ",")
+		if len(fields) != 3 {
+			return nil, errors.New("Improper known optimum format in line: " + line)
+		}
+		cycle_time, cycle_err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		stations, stations_err := strconv.Atoi(strings.TrimSpace(fields[2]))
+		if cycle_err != nil || stations_err != nil {
+			return nil, errors.New("Improper known optimum format in line: " + line)
+		}
+		optima[optimaKey(strings.TrimSpace(fields[0]), cycle_time)] = stations
+	}
+	return optima, scanner.Err()
+}
+
+///////////////////////////////////
+// Bench reports
+///////////////////////////////////
+
+var bench_columns = []string{"spec", "solver", "cycle_time", "tasks", "stations", "lower_bound",
+	"lb_gap", "optimum", "efficiency", "smoothness", "time_ms", "status"}
+
+// Returns the fields of a result in the order of bench_columns, fields that
+// weren't measured are empty
+func (br *BenchResult) toFields() []string {
+	fields := []string{br.spec, br.solver, costToStr(br.cycleTime), strconv.Itoa(br.numTasks),
+		"", "", "", "", "", "", "", br.status}
+
+	if br.lowerBound != 0 {
+		fields[5] = strconv.Itoa(br.lowerBound)
+	}
+	if br.optimum != 0 {
+		fields[7] = strconv.Itoa(br.optimum)
+	}
+	fields[10] = strconv.FormatFloat(br.wallTime.Seconds()*1000.0, 'f', 3, 64)
+
+	if br.stations != 0 {
+		fields[4] = strconv.Itoa(br.stations)
+		fields[6] = formatPercent(br.lowerBoundGap())
+		fields[8] = formatPercent(br.efficiency)
+		fields[9] = strconv.FormatFloat(br.smoothness, 'f', 1, 64)
+	}
+	return fields
+}
+
+// Share of stations used beyond the lower bound
+func (br *BenchResult) lowerBoundGap() float64 {
+	if br.lowerBound == 0 {
+		return 0.0
+	}
+	return float64(br.stations-br.lowerBound) / float64(br.lowerBound)
+}
+
+func formatPercent(share float64) string {
+	return strconv.FormatFloat(share*100.0, 'f', 1, 64) + "%"
+}
+
+// Returns the results as CSV with a header line
+func BenchResultsToCSV(results []BenchResult) string {
+	str := strings.Join(bench_columns, ",") + "\n"
+	for i, _ := range results {
+		str += strings.Join(results[i].toFields(), ",") + "\n"
+	}
+	return str
+}
+
+// Returns the results as a Markdown table followed by a table summarizing each
+// solver over all specs
+func BenchResultsToMarkdown(results []BenchResult) string {
+	str := "| " + strings.Join(bench_columns, " | ") + " |\n"
+	str += strings.Repeat("|---", len(bench_columns)) + "|\n"
+	for i, _ := range results {
+		str += "| " + strings.Join(results[i].toFields(), " | ") + " |\n"
+	}
+
+	str += "\n| solver | runs | solved | at_lower_bound | at_optimum | mean_lb_gap | time_ms |\n"
+	str += strings.Repeat("|---", 7) + "|\n"
+
+	var solver_order []string
+	by_solver := make(map[string][]*BenchResult)
+	for i, _ := range results {
+		name := results[i].solver
+		if _, ok := by_solver[name]; !ok {
+			solver_order = append(solver_order, name)
+		}
+		by_solver[name] = append(by_solver[name], &results[i])
+	}
+
+	for _, name := range solver_order {
+		num_solved, num_at_lb, num_at_opt := 0, 0, 0
+		gap_sum := 0.0
+		var total_time time.Duration
+		for _, br := range by_solver[name] {
+			total_time += br.wallTime
+			if br.status != "optimal" && br.status != "ok" {
+				continue
+			}
+			num_solved++
+			gap_sum += br.lowerBoundGap()
+			if br.stations == br.lowerBound {
+				num_at_lb++
+			}
+			if br.stations == br.optimum {
+				num_at_opt++
+			}
+		}
+
+		mean_gap := ""
+		if num_solved != 0 {
+			mean_gap = formatPercent(gap_sum / float64(num_solved))
+		}
+		str += fmt.Sprintf("| %s | %d | %d | %d | %d | %s | %.3f |\n", name, len(by_solver[name]),
+			num_solved, num_at_lb, num_at_opt, mean_gap, total_time.Seconds()*1000.0)
+	}
+	return str
+}
@@ -0,0 +1,578 @@
+package pwlb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Smallest change in line cost the cost solver counts as a change
+const k_cost_epsilon = 1e-9
+
+// A piece of equipment tasks may need, like a press or a welding robot. Every
+// station with a task that needs it has its own copy.
+type Equipment struct {
+	name string
+	cost float64
+}
+
+func (eq *Equipment) GetName() string {
+	return eq.name
+}
+
+func (eq *Equipment) GetCost() float64 {
+	return eq.cost
+}
+
+// Returns equipment as a spec line like "equipment,press,1200.0"
+func (eq *Equipment) ToStr() string {
+	return "equipment," + eq.name + "," + costToStr(eq.cost)
+}
+
+// Returns the names of the equipment the task needs at its station
+func (t *Task) GetEquipment() []string {
+	return t.equip
+}
+
+func (tc *TaskContainer) GetEquipment() []Equipment {
+	return tc.equipment
+}
+
+// Returns the fixed cost of each station, zero unless the spec gives one
+func (tc *TaskContainer) GetStationCost() float64 {
+	return tc.stationCost
+}
+
+// Returns true if the spec gives a station cost or equipment
+func (tc *TaskContainer) HasCosts() bool {
+	return tc.stationCost != 0.0 || len(tc.equipment) != 0
+}
+
+// Returns the index of the equipment with the name, -1 if there is none
+func (tc *TaskContainer) equipmentIdx(name string) int {
+	for i, _ := range tc.equipment {
+		if tc.equipment[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Parses a line like "equipment,press,1200" naming a piece of equipment and the
+// cost of each copy of it
+func (tc *TaskContainer) parseEquipment(fields []string) error {
+	if len(fields) != 3 || strings.TrimSpace(fields[1]) == "" {
+		return errors.New("Improper equipment format")
+	}
+	name := strings.TrimSpace(fields[1])
+	if tc.equipmentIdx(name) != -1 {
+		return errors.New("Equipment " + name + " listed twice")
+	}
+	cost, err := strconv.ParseFloat(strings.TrimSpace(fields[2]), 64)
+	if err != nil || cost < 0.0 {
+		return errors.New("Equipment cost must be a number no less than zero")
+	}
+
+	tc.equipment = append(tc.equipment, Equipment{name, cost})
+	return nil
+}
+
+// Parses a line like "station_cost,500", the fixed cost of each station
+func (tc *TaskContainer) parseStationCost(fields []string) error {
+	if len(fields) != 2 {
+		return errors.New("Improper station cost format")
+	}
+	cost, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+	if err != nil || cost < 0.0 {
+		return errors.New("Station cost must be a number no less than zero")
+	}
+	tc.stationCost = cost
+	return nil
+}
+
+// Stops when a task needs equipment that isn't in the spec
+func (tc *TaskContainer) checkTaskEquipment() {
+	for i, _ := range tc.tasks {
+		for _, name := range tc.tasks[i].equip {
+			if tc.equipmentIdx(name) == -1 {
+				log.Fatal("Task " + strconv.Itoa(int(tc.tasks[i].id)) + " needs unknown equipment " + name)
+			}
+		}
+	}
+}
+
+// Returns the equipment each task needs by index into the container's
+// equipment, each piece once, by container index
+func (tc *TaskContainer) taskEquipmentIdxs() [][]int {
+	idxs := make([][]int, len(tc.tasks))
+	for i, _ := range tc.tasks {
+		for _, name := range tc.tasks[i].equip {
+			eq := tc.equipmentIdx(name)
+			found := false
+			for _, other := range idxs[i] {
+				found = found || other == eq
+			}
+			if !found {
+				idxs[i] = append(idxs[i], eq)
+			}
+		}
+	}
+	return idxs
+}
+
+///////////////////////////////////
+// Line cost
+///////////////////////////////////
+
+// Cost of one station of a line, counting every copy of a replicated station
+type StationCost struct {
+	station       WorkstationId_t
+	fixed         float64
+	equipment     []string // equipment at the station in the order of the spec
+	equipmentCost float64
+}
+
+func (sc *StationCost) GetStation() WorkstationId_t {
+	return sc.station
+}
+
+func (sc *StationCost) GetFixedCost() float64 {
+	return sc.fixed
+}
+
+func (sc *StationCost) GetEquipment() []string {
+	return sc.equipment
+}
+
+func (sc *StationCost) GetEquipmentCost() float64 {
+	return sc.equipmentCost
+}
+
+func (sc *StationCost) GetTotalCost() float64 {
+	return sc.fixed + sc.equipmentCost
+}
+
+// Copies of a piece of equipment a line has, one at each station that needs it
+type EquipmentUse struct {
+	name   string
+	copies int
+	cost   float64 // of all the copies
+}
+
+func (eu *EquipmentUse) GetName() string {
+	return eu.name
+}
+
+func (eu *EquipmentUse) GetCopies() int {
+	return eu.copies
+}
+
+func (eu *EquipmentUse) GetCost() float64 {
+	return eu.cost
+}
+
+// Cost of a line by station and by piece of equipment
+type CostBreakdown struct {
+	stations  []StationCost  // by station id
+	equipment []EquipmentUse // in the order of the spec, only what the line uses
+}
+
+func (cb *CostBreakdown) GetStations() []StationCost {
+	return cb.stations
+}
+
+func (cb *CostBreakdown) GetEquipment() []EquipmentUse {
+	return cb.equipment
+}
+
+// Returns the fixed cost of all stations
+func (cb *CostBreakdown) GetFixedCost() float64 {
+	fixed := 0.0
+	for i, _ := range cb.stations {
+		fixed += cb.stations[i].fixed
+	}
+	return fixed
+}
+
+// Returns the cost of all equipment at all stations
+func (cb *CostBreakdown) GetEquipmentCost() float64 {
+	equipment_cost := 0.0
+	for i, _ := range cb.stations {
+		equipment_cost += cb.stations[i].equipmentCost
+	}
+	return equipment_cost
+}
+
+// Returns the cost of the copies of equipment beyond the first of each piece,
+// what splitting tasks that need the same equipment over stations costs
+func (cb *CostBreakdown) GetDuplicatedCost() float64 {
+	duplicated := 0.0
+	for i, _ := range cb.equipment {
+		duplicated += cb.equipment[i].cost * float64(cb.equipment[i].copies-1) / float64(cb.equipment[i].copies)
+	}
+	return duplicated
+}
+
+func (cb *CostBreakdown) GetTotalCost() float64 {
+	return cb.GetFixedCost() + cb.GetEquipmentCost()
+}
+
+// Returns the cost of each station of the solution, its fixed cost plus a copy
+// of the equipment its tasks need, and how many copies of each piece of
+// equipment the line has. A replicated station costs as much for each copy.
+func (sol *PartialSolution) GetCostBreakdown() *CostBreakdown {
+	tc := sol.getTaskContainer()
+	equip := tc.taskEquipmentIdxs()
+
+	needed := make(map[WorkstationId_t][]bool)
+	var wsids []WorkstationId_t
+	for _, asg := range sol.assignments {
+		if _, ok := needed[asg.j]; !ok {
+			needed[asg.j] = make([]bool, len(tc.equipment))
+			wsids = append(wsids, asg.j)
+		}
+		for _, eq := range equip[tc.taskMapping[asg.i]] {
+			needed[asg.j][eq] = true
+		}
+	}
+	sort.Slice(wsids, func(a, b int) bool {
+		return wsids[a] < wsids[b]
+	})
+
+	cb := &CostBreakdown{}
+	copies := make([]int, len(tc.equipment))
+	for _, wsid := range wsids {
+		replicas := sol.GetReplicas(wsid)
+		sc := StationCost{station: wsid, fixed: tc.stationCost * float64(replicas)}
+		for eq, is_needed := range needed[wsid] {
+			if is_needed {
+				sc.equipment = append(sc.equipment, tc.equipment[eq].name)
+				sc.equipmentCost += tc.equipment[eq].cost * float64(replicas)
+				copies[eq] += replicas
+			}
+		}
+		cb.stations = append(cb.stations, sc)
+	}
+	for eq, num_copies := range copies {
+		if num_copies != 0 {
+			cb.equipment = append(cb.equipment, EquipmentUse{tc.equipment[eq].name, num_copies,
+				tc.equipment[eq].cost * float64(num_copies)})
+		}
+	}
+	return cb
+}
+
+// Returns the fixed cost of the stations of the solution plus the cost of the
+// equipment at them
+func (sol *PartialSolution) GetTotalCost() float64 {
+	return sol.GetCostBreakdown().GetTotalCost()
+}
+
+// Returns a line for each station like
+// "Station 2:      Fixed 500.00   Equipment 1200.00   press welder"
+// followed by a line for each piece of equipment like
+// "Equipment press:      Copies 2   Cost 2400.00"
+func PrettyCostStr(cb *CostBreakdown) string {
+	str := ""
+	for i, _ := range cb.stations {
+		sc := &cb.stations[i]
+		str += "Station " + strconv.Itoa(int(sc.station)) + ":      "
+		str += "Fixed " + strconv.FormatFloat(sc.fixed, 'f', 2, 64)
+		str += "   Equipment " + strconv.FormatFloat(sc.equipmentCost, 'f', 2, 64)
+		if len(sc.equipment) != 0 {
+			str += "   " + strings.Join(sc.equipment, " ")
+		}
+		str += "\n"
+	}
+	for i, _ := range cb.equipment {
+		eu := &cb.equipment[i]
+		str += "Equipment " + eu.name + ":      "
+		str += "Copies " + strconv.Itoa(eu.copies)
+		str += "   Cost " + strconv.FormatFloat(eu.cost, 'f', 2, 64) + "\n"
+	}
+	return str
+}
+
+///////////////////////////////////
+// Cost-oriented solve
+///////////////////////////////////
+
+// Assigns the tasks in the container to stations for the lowest line cost, the
+// fixed cost of the stations plus a copy of every piece of equipment at each
+// station with a task that needs it, and then the fewest stations. Two lines are
+// filled station by station, one taking the available task with the largest
+// positional weight that fits and one the task that adds the least equipment to
+// the station, by weight on a tie. Each is improved by moving single tasks to
+// another station their prereqs and postreqs allow while that lowers the cost
+// or, at the same cost, empties a station, and the cheaper line is kept. Like
+// the other heuristics it may miss a cheaper line. The global containers are
+// not used.
+//
+// Only straight lines without zones, pins, setup times, task times that vary or
+// tasks over the cycle time are solved. Without station or equipment costs the
+// lines only differ in their stations.
+func SolveCost(ctx context.Context, tc *TaskContainer, opts *SolveOptions) *SolveResult {
+	if len(tc.tasks) == 0 {
+		return newCompletedResult(&PartialSolution{tc: tc})
+	}
+	if err := checkCostSolver(tc, opts); err != nil {
+		panic(err.Error())
+	}
+
+	progress := newSolveProgress(opts)
+	progress.bound(tc.GetLowerBound())
+
+	cs := &costSearch{tc: tc, graph: tc.GetGraph(), less: rpwLess(tc), equip: tc.taskEquipmentIdxs()}
+	var best *PartialSolution
+	best_cost := 0.0
+	for _, by_cost := range []bool{false, true} {
+		if ctx.Err() != nil {
+			break
+		}
+		station, order := cs.fill(by_cost)
+		cs.improve(ctx, station)
+		sol := cs.toSolution(station, order)
+		progress.iteration()
+
+		cost := sol.GetTotalCost()
+		if best == nil || cost < best_cost-k_cost_epsilon ||
+			(cost < best_cost+k_cost_epsilon && sol.GetMeasuredMin() < best.GetMeasuredMin()) {
+			best, best_cost = sol, cost
+			progress.incumbent(sol)
+		}
+	}
+
+	if best == nil {
+		return &SolveResult{&PartialSolution{tc: tc}, SolveInterrupted}
+	}
+	if ctx.Err() != nil {
+		return &SolveResult{best, SolveInterrupted}
+	}
+	if !tc.HasCosts() {
+		return newCompletedResult(best)
+	}
+	return &SolveResult{best, SolveCompleted}
+}
+
+// Returns an error saying why SolveCost can't solve the problem, nil when it can
+func checkCostSolver(tc *TaskContainer, opts *SolveOptions) error {
+	switch {
+	case tc.layout != LayoutStraight:
+		return errors.New("The cost solver only solves straight lines, use another solver")
+	case len(tc.zones) != 0:
+		return errors.New("The cost solver doesn't keep zones, use another solver")
+	case tc.HasSetups():
+		return errors.New("The cost solver doesn't add setup times, use another solver")
+	case opts.getServiceZ() != 0.0:
+		return errors.New("The cost solver doesn't meet a service level, use another solver")
+	}
+	for i, _ := range tc.tasks {
+		if tc.tasks[i].pin != nil {
+			return errors.New("The cost solver doesn't keep the pin of task " + tc.pinStr(i) + ", use another solver")
+		}
+		if tc.tasks[i].cost > tc.cycleTime+k_time_epsilon {
+			return errors.New("The cost solver doesn't replicate stations for task " +
+				tc.tasks[i].ToStr() + " over the cycle time, use another solver")
+		}
+	}
+	return nil
+}
+
+// State of SolveCost, fixed over the lines it fills
+type costSearch struct {
+	tc    *TaskContainer
+	graph *TaskGraph
+	less  func(lhs, rhs int) bool // ranked positional weight, larger first
+	equip [][]int                 // equipment each task needs by container index
+}
+
+// Returns the cost a task adds to a station that has the given number of tasks
+// needing each piece of equipment
+func (cs *costSearch) addedCost(idx int, counts []int) float64 {
+	added := 0.0
+	for _, eq := range cs.equip[idx] {
+		if counts[eq] == 0 {
+			added += cs.tc.equipment[eq].cost
+		}
+	}
+	return added
+}
+
+// Fills stations one at a time and returns the station of each task by
+// container index, counted from zero, and the tasks in the order they were
+// assigned. When by_cost is true the task adding the least equipment cost is
+// taken first.
+func (cs *costSearch) fill(by_cost bool) ([]int, []int) {
+	tc := cs.tc
+	num_tasks := len(tc.tasks)
+	station := make([]int, num_tasks)
+	indegree := make([]int, num_tasks)
+	var avail, order []int
+	for i, _ := range tc.tasks {
+		indegree[i] = len(cs.graph.prereqs[i])
+		if indegree[i] == 0 {
+			avail = append(avail, i)
+		}
+	}
+
+	cur, load := 0, 0.0
+	counts := make([]int, len(tc.equipment))
+	for len(order) != num_tasks {
+		pick, pick_added := -1, 0.0
+		for pos, idx := range avail {
+			if load+tc.tasks[idx].cost > tc.cycleTime+k_time_epsilon {
+				continue
+			}
+			added := cs.addedCost(idx, counts)
+			better := pick == -1
+			if !better && by_cost && added < pick_added-k_cost_epsilon {
+				better = true
+			} else if !better && (!by_cost || added < pick_added+k_cost_epsilon) {
+				better = cs.less(idx, avail[pick])
+			}
+			if better {
+				pick, pick_added = pos, added
+			}
+		}
+
+		// Open the next station when no task fits
+		if pick == -1 {
+			cur, load = cur+1, 0.0
+			counts = make([]int, len(tc.equipment))
+			continue
+		}
+
+		idx := avail[pick]
+		avail = append(avail[:pick], avail[pick+1:]...)
+		station[idx] = cur
+		order = append(order, idx)
+		load += tc.tasks[idx].cost
+		for _, eq := range cs.equip[idx] {
+			counts[eq]++
+		}
+		for _, post_idx := range cs.graph.postreqs[idx] {
+			indegree[post_idx]--
+			if indegree[post_idx] == 0 {
+				avail = append(avail, post_idx)
+			}
+		}
+	}
+	return station, order
+}
+
+// Moves single tasks to other stations with room while that lowers the cost of
+// the line or, at the same cost, empties a station. A task can go to any station
+// from the last of its prereqs to the first of its postreqs.
+func (cs *costSearch) improve(ctx context.Context, station []int) {
+	tc := cs.tc
+	num_stations := 0
+	for _, s := range station {
+		if s+1 > num_stations {
+			num_stations = s + 1
+		}
+	}
+	loads := make([]float64, num_stations)
+	sizes := make([]int, num_stations)
+	counts := make([][]int, num_stations)
+	for s, _ := range counts {
+		counts[s] = make([]int, len(tc.equipment))
+	}
+	for idx, s := range station {
+		loads[s] += tc.tasks[idx].cost
+		sizes[s]++
+		for _, eq := range cs.equip[idx] {
+			counts[s][eq]++
+		}
+	}
+
+	for improved := true; improved && ctx.Err() == nil; {
+		improved = false
+		for idx, _ := range tc.tasks {
+			from := station[idx]
+			first, last := 0, num_stations-1
+			for _, prereq_idx := range cs.graph.prereqs[idx] {
+				if station[prereq_idx] > first {
+					first = station[prereq_idx]
+				}
+			}
+			for _, post_idx := range cs.graph.postreqs[idx] {
+				if station[post_idx] < last {
+					last = station[post_idx]
+				}
+			}
+
+			// What leaving its station saves
+			saved := 0.0
+			for _, eq := range cs.equip[idx] {
+				if counts[from][eq] == 1 {
+					saved += tc.equipment[eq].cost
+				}
+			}
+			if sizes[from] == 1 {
+				saved += tc.stationCost
+			}
+
+			best_to, best_delta := -1, 0.0
+			for to := first; to <= last; to++ {
+				if to == from || sizes[to] == 0 || loads[to]+tc.tasks[idx].cost > tc.cycleTime+k_time_epsilon {
+					continue
+				}
+				delta := cs.addedCost(idx, counts[to]) - saved
+				if delta >= k_cost_epsilon || (delta > -k_cost_epsilon && sizes[from] != 1) {
+					continue
+				}
+				if best_to == -1 || delta < best_delta-k_cost_epsilon {
+					best_to, best_delta = to, delta
+				}
+			}
+			if best_to == -1 {
+				continue
+			}
+
+			loads[from] -= tc.tasks[idx].cost
+			loads[best_to] += tc.tasks[idx].cost
+			sizes[from]--
+			sizes[best_to]++
+			for _, eq := range cs.equip[idx] {
+				counts[from][eq]--
+				counts[best_to][eq]++
+			}
+			station[idx] = best_to
+			improved = true
+		}
+	}
+}
+
+// Returns the solution with the tasks at their stations, numbered without gaps
+// from the first task id, each station listing its tasks in the given order
+func (cs *costSearch) toSolution(station []int, order []int) *PartialSolution {
+	tc := cs.tc
+	used := make(map[int]bool)
+	for _, s := range station {
+		used[s] = true
+	}
+	var stations []int
+	for s, _ := range used {
+		stations = append(stations, s)
+	}
+	sort.Ints(stations)
+	wsids := make(map[int]WorkstationId_t)
+	for rank, s := range stations {
+		wsids[s] = WorkstationId_t(int(tc.tasks[0].id) + rank)
+	}
+
+	sorted := make([]int, len(order))
+	copy(sorted, order)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return station[sorted[a]] < station[sorted[b]]
+	})
+
+	sol := &PartialSolution{tc: tc, assignments: make([]TaskAssignment, 0, len(sorted))}
+	for _, idx := range sorted {
+		sol.assignments = append(sol.assignments, TaskAssignment{tc.tasks[idx].id, wsids[station[idx]]})
+	}
+	return sol
+}
@@ -0,0 +1,148 @@
+package pwlb
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+)
+
+// Differences between two solutions of the same tasks
+type SolutionDiff struct {
+	before, after *PartialSolution
+	moves         []TaskMove        // tasks at another station after, by task id
+	added         []WorkstationId_t // stations only the solution after uses
+	removed       []WorkstationId_t // stations only the solution before uses
+}
+
+// Compares two solutions of the same tasks. Fails if a task isn't assigned
+// exactly once in both of them. Only a change of station counts as a move, a task
+// that stays at its station but goes to the back of a U-line or to the other side
+// of a two-sided line isn't listed.
+func DiffSolutions(before, after *PartialSolution) (*SolutionDiff, error) {
+	diff := &SolutionDiff{before: before, after: after}
+	if err := before.checkAssignedOnce("before"); err != nil {
+		return nil, err
+	}
+	if err := after.checkAssignedOnce("after"); err != nil {
+		return nil, err
+	}
+
+	before_station := make(map[TaskId_t]WorkstationId_t)
+	before_used := make(map[WorkstationId_t]bool)
+	for _, asg := range before.assignments {
+		before_station[asg.i] = asg.j
+		before_used[asg.j] = true
+	}
+
+	after_used := make(map[WorkstationId_t]bool)
+	for _, asg := range after.assignments {
+		after_used[asg.j] = true
+		if from := before_station[asg.i]; from != asg.j {
+			diff.moves = append(diff.moves, TaskMove{kind: TaskMoved, task: asg.i, from: from, to: asg.j})
+		}
+	}
+
+	for wsid, _ := range after_used {
+		if !before_used[wsid] {
+			diff.added = append(diff.added, wsid)
+		}
+	}
+	for wsid, _ := range before_used {
+		if !after_used[wsid] {
+			diff.removed = append(diff.removed, wsid)
+		}
+	}
+
+	sort.Slice(diff.moves, func(a, b int) bool {
+		return diff.moves[a].task < diff.moves[b].task
+	})
+	sort.Slice(diff.added, func(a, b int) bool {
+		return diff.added[a] < diff.added[b]
+	})
+	sort.Slice(diff.removed, func(a, b int) bool {
+		return diff.removed[a] < diff.removed[b]
+	})
+	return diff, nil
+}
+
+// Returns an error for the first task of the container the solution assigns more
+// than once or not at all, naming the solution as before or after
+func (sol *PartialSolution) checkAssignedOnce(name string) error {
+	tc := sol.getTaskContainer()
+	assigned := make(map[TaskId_t]bool)
+	for _, asg := range sol.assignments {
+		if assigned[asg.i] {
+			return errors.New("Task " + strconv.Itoa(int(asg.i)) + " is assigned more than once " + name)
+		}
+		assigned[asg.i] = true
+	}
+	for i, _ := range tc.tasks {
+		if !assigned[tc.tasks[i].id] {
+			return errors.New("Task " + strconv.Itoa(int(tc.tasks[i].id)) + " isn't assigned " + name)
+		}
+	}
+	return nil
+}
+
+func (diff *SolutionDiff) GetMoves() []TaskMove {
+	return diff.moves
+}
+
+func (diff *SolutionDiff) GetAddedStations() []WorkstationId_t {
+	return diff.added
+}
+
+func (diff *SolutionDiff) GetRemovedStations() []WorkstationId_t {
+	return diff.removed
+}
+
+// Returns the workstations after less those before
+func (diff *SolutionDiff) GetMeasuredMinChange() int {
+	return diff.after.GetMeasuredMin() - diff.before.GetMeasuredMin()
+}
+
+// Returns the line efficiency after less the one before
+func (diff *SolutionDiff) GetLineEfficiencyChange() float64 {
+	return diff.after.GetLineEfficiency() - diff.before.GetLineEfficiency()
+}
+
+// Returns the smoothness index after less the one before
+func (diff *SolutionDiff) GetSmoothnessIndexChange() float64 {
+	return diff.after.GetSmoothnessIndex() - diff.before.GetSmoothnessIndex()
+}
+
+// Returns the metrics before and after with their change, one per line like
+// "measured_min=8 -> 9 (+1)"
+func PrettyDiffMetricsStr(diff *SolutionDiff) string {
+	str := "measured_min=" + strconv.Itoa(diff.before.GetMeasuredMin()) + " -> " +
+		strconv.Itoa(diff.after.GetMeasuredMin()) + " (" + signedStr(float64(diff.GetMeasuredMinChange()), 0) + ")\n"
+	str += "line_efficiency=" + diff.before.GetLineEfficiencyStr() + " -> " + diff.after.GetLineEfficiencyStr() +
+		" (" + signedStr(diff.GetLineEfficiencyChange()*100.0, 1) + ")\n"
+	str += "smoothness_index=" + diff.before.GetSmoothnessIndexStr() + " -> " + diff.after.GetSmoothnessIndexStr() +
+		" (" + signedStr(diff.GetSmoothnessIndexChange(), 1) + ")\n"
+	return str
+}
+
+// Returns a line for each station added or removed and each task moved
+func PrettyDiffStr(diff *SolutionDiff) string {
+	str := ""
+	for _, wsid := range diff.added {
+		str += "Station " + strconv.Itoa(int(wsid)) + ":      Added\n"
+	}
+	for _, wsid := range diff.removed {
+		str += "Station " + strconv.Itoa(int(wsid)) + ":      Removed\n"
+	}
+	for i, _ := range diff.moves {
+		str += diff.moves[i].ToStr() + "\n"
+	}
+	return str
+}
+
+// Returns a number with its sign, like "+1.5" or "-2"
+func signedStr(value float64, prec int) string {
+	str := strconv.FormatFloat(value, 'f', prec, 64)
+	if value >= 0.0 && str[0] != '-' {
+		str = "+" + str
+	}
+	return str
+}
@@ -1,6 +1,8 @@
 package pwlb
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math"
 	"sort"
@@ -8,6 +10,7 @@ import (
 	//"strings"
 )
 
+// Cycle time of a task container unless another is set
 const k_cycle_time = float64(50.0)
 
 func GetTheoreticalMin() int {
@@ -18,7 +21,13 @@ func GetTheoreticalMin() int {
 		task_time_sum += tc.tasks[i].cost
 	}
 
-	min := int(math.Ceil(task_time_sum / k_cycle_time))
+	// Both sides of a two-sided station work the cycle time
+	capacity := tc.cycleTime
+	if tc.layout == LayoutTwoSided {
+		capacity *= 2.0
+	}
+
+	min := int(math.Ceil(task_time_sum / capacity))
 	return min
 }
 
@@ -28,6 +37,7 @@ func PrettySolutionStr(sol *PartialSolution) string {
 
 	station_id := WorkstationId_t(tc.tasks[0].id)
 	num_stations := len(wsc.workstations)
+	setups := sol.getStationSetups()
 
 	str := ""
 	for i := 0; i < num_stations; i++ {
@@ -38,19 +48,59 @@ func PrettySolutionStr(sol *PartialSolution) string {
 			break
 		}
 
-		station_cost := this_ws.GetCost()
+		station_cost := this_ws.GetCost() + setups[this_ws.id]
+		if sol.workers != nil {
+			station_cost = sol.getOperatorLoads()[operatorKey{ws: this_ws.id}]
+		}
 
-		// Sort unassigned tasks ids on task time
-		sort.Slice(this_ws.tasks, func(a, b int) bool {
-			lhs := this_ws.tasks[a]
-			rhs := this_ws.tasks[b]
-			return lhs < rhs
-		})
+		// Sort unassigned tasks ids on task time, with setup times the tasks are
+		// listed in the order they are done
+		if setups == nil {
+			sort.Slice(this_ws.tasks, func(a, b int) bool {
+				lhs := this_ws.tasks[a]
+				rhs := this_ws.tasks[b]
+				return lhs < rhs
+			})
+		}
 
 		str += "Station " + strconv.Itoa(int(this_ws.id)) + ":      "
-		str += "TaskTime " + strconv.FormatFloat(station_cost, 'f', 2, 64) + "   Tasks"
+		str += "TaskTime " + strconv.FormatFloat(station_cost, 'f', 2, 64)
+		if setups != nil {
+			str += "   Setup " + strconv.FormatFloat(setups[this_ws.id], 'f', 2, 64)
+		}
+		if sol.workers != nil {
+			str += "   Worker " + sol.GetWorker(this_ws.id)
+		}
+		if sol.operators != nil {
+			str += "   Operators " + strconv.Itoa(sol.GetStationOperators(this_ws.id))
+		}
+		if replicas := sol.GetReplicas(this_ws.id); replicas > 1 {
+			str += "   Replicas " + strconv.Itoa(replicas)
+		}
+
+		// On a two-sided line each side has its own load and tasks
+		if tc.layout == LayoutTwoSided {
+			str += prettySidesStr(sol, tc, this_ws) + "\n"
+			station_id++
+			continue
+		}
+
+		str += "   Tasks"
+		for j, _ := range this_ws.tasks {
+			if !sol.IsOnBack(this_ws.tasks[j]) {
+				str += " " + strconv.Itoa(int(this_ws.tasks[j]))
+			}
+		}
+
+		// On a U-line the tasks done on the way back are listed apart
+		back_tasks := ""
 		for j, _ := range this_ws.tasks {
-			str += " " + strconv.Itoa(int(this_ws.tasks[j]))
+			if sol.IsOnBack(this_ws.tasks[j]) {
+				back_tasks += " " + strconv.Itoa(int(this_ws.tasks[j]))
+			}
+		}
+		if tc.layout == LayoutU && back_tasks != "" {
+			str += "   Back" + back_tasks
 		}
 
 		str += "\n"
@@ -60,6 +110,29 @@ func PrettySolutionStr(sol *PartialSolution) string {
 	return str
 }
 
+// Returns the load and tasks of each side of a two-sided station like
+// "   Left 45.00 Tasks 1 2   Right 30.00 Tasks 3"
+func prettySidesStr(sol *PartialSolution, tc *TaskContainer, ws *Workstation) string {
+	str := ""
+	for _, side := range []TaskSide{SideLeft, SideRight} {
+		side_cost := 0.0
+		side_tasks := ""
+		for _, taskid := range ws.tasks {
+			if sol.GetSide(taskid) == side {
+				side_cost += tc.tasks[tc.taskMapping[taskid]].cost
+				side_tasks += " " + strconv.Itoa(int(taskid))
+			}
+		}
+
+		name := "Left"
+		if side == SideRight {
+			name = "Right"
+		}
+		str += "   " + name + " " + strconv.FormatFloat(side_cost, 'f', 2, 64) + " Tasks" + side_tasks
+	}
+	return str
+}
+
 type TaskAssignment struct {
 	i TaskId_t
 	j WorkstationId_t
@@ -67,6 +140,35 @@ type TaskAssignment struct {
 
 type PartialSolution struct {
 	assignments []TaskAssignment
+
+	// Tasks done on the back side of a U-line, nil on a straight line
+	back map[TaskId_t]bool
+
+	// Side and start time within the cycle of every task on a two-sided line, the
+	// start times are also kept for multi-manned stations
+	sides  map[TaskId_t]TaskSide
+	starts map[TaskId_t]float64
+
+	// Operator of every task at multi-manned stations counted from one, nil
+	// unless the stations are multi-manned
+	operators map[TaskId_t]int
+
+	// Copies of the stations that are replicated, the others have one
+	replicas map[WorkstationId_t]int
+
+	// Worker at each station by index into the container's workers, nil unless
+	// a crew was assigned
+	workers map[WorkstationId_t]int
+
+	// Tasks the solution was made for, the global container when nil
+	tc *TaskContainer
+}
+
+func (sol *PartialSolution) getTaskContainer() *TaskContainer {
+	if sol.tc != nil {
+		return sol.tc
+	}
+	return GetTaskContainer()
 }
 
 func (sol *PartialSolution) ToStr() string {
@@ -112,23 +214,35 @@ func (sol *PartialSolution) getNumActiveWorkstations() int {
 	return len(workstation_counter)
 }
 
+// Returns the number of workstations used, counting every copy of a replicated
+// station
 func (sol *PartialSolution) GetMeasuredMin() int {
-	return sol.getNumActiveWorkstations()
+	num_workstations := sol.getNumActiveWorkstations()
+	for _, replicas := range sol.replicas {
+		num_workstations += replicas - 1
+	}
+	return num_workstations
 }
 
 func (sol *PartialSolution) GetLineEfficiency() float64 {
-	tc := GetTaskContainer()
+	tc := sol.getTaskContainer()
 
-	num_workstations := sol.getNumActiveWorkstations()
+	// Each used side of a two-sided station and each copy of a replicated station
+	// counts as a workstation
+	num_workstations := 0
+	for key, _ := range sol.getOperatorLoads() {
+		num_workstations += sol.GetReplicas(key.ws)
+	}
 	if num_workstations == 0 {
 		return 0.0
 	}
 
-	denom := k_cycle_time * float64(num_workstations)
+	denom := tc.cycleTime * float64(num_workstations)
 
-	task_time_sum := 0.0
+	// Setup times keep the stations busy like task times do
+	task_time_sum := sol.GetSetupTime()
 	for _, task_asg := range sol.assignments {
-		task_time_sum += tc.GetTaskReadOnly(task_asg.i).cost
+		task_time_sum += sol.taskTime(tc, tc.taskMapping[task_asg.i], task_asg.j)
 	}
 
 	return task_time_sum / denom
@@ -141,30 +255,51 @@ func (sol *PartialSolution) GetLineEfficiencyStr() string {
 }
 
 func (sol *PartialSolution) GetSmoothnessIndex() float64 {
-	tc := GetTaskContainer()
-
-	// Make a map to hold the sum task cost in a workstation and initialize it
-	// Only workstations that have task assignments are initialized
-	workstation_costs := make(map[WorkstationId_t]float64)
-	for i, _ := range sol.assignments {
-		workstation_costs[sol.assignments[i].j] = 0.0
-	}
-
-	// Calculate the sum of task costs at each used workstation
-	for i, _ := range sol.assignments {
-		task_cost := tc.tasks[tc.taskMapping[sol.assignments[i].i]].cost
-		workstation_costs[sol.assignments[i].j] += task_cost
-	}
+	tc := sol.getTaskContainer()
 
+	// A replicated station has the cycle time of all its copies
 	smoothness_acc := 0.0
-	for _, val := range workstation_costs {
-		smoothness_acc += math.Pow(k_cycle_time-val, 2.0)
+	for key, val := range sol.getOperatorLoads() {
+		smoothness_acc += math.Pow(tc.cycleTime*float64(sol.GetReplicas(key.ws))-val, 2.0)
 	}
 
 	smoothness_acc = math.Sqrt(smoothness_acc)
 	return smoothness_acc
 }
 
+// A workstation, one side of a two-sided station or one operator of a
+// multi-manned station
+type operatorKey struct {
+	ws   WorkstationId_t
+	side TaskSide // SideEither unless two-sided
+	op   int      // counted from one, zero unless multi-manned
+}
+
+// Returns the operator within the station like "side L" or "operator 2"
+func (key operatorKey) ToStr() string {
+	if key.op != 0 {
+		return "operator " + strconv.Itoa(key.op)
+	}
+	return "side " + key.side.ToStr()
+}
+
+// Returns the sum task cost at each workstation with tasks, on a two-sided line
+// at each side of a station with tasks, setup times included
+func (sol *PartialSolution) getOperatorLoads() map[operatorKey]float64 {
+	tc := sol.getTaskContainer()
+
+	workstation_costs := make(map[operatorKey]float64)
+	for i, _ := range sol.assignments {
+		taskid := sol.assignments[i].i
+		key := operatorKey{sol.assignments[i].j, sol.sides[taskid], sol.operators[taskid]}
+		workstation_costs[key] += sol.taskTime(tc, tc.taskMapping[sol.assignments[i].i], sol.assignments[i].j)
+	}
+	for key, setup := range sol.getOperatorSetups() {
+		workstation_costs[key] += setup
+	}
+	return workstation_costs
+}
+
 func (sol *PartialSolution) GetSmoothnessIndexStr() string {
 	smooth := sol.GetSmoothnessIndex()
 	str := strconv.FormatFloat(smooth, 'f', 1, 64)
@@ -179,7 +314,7 @@ func workstationCapacityRemaining(ws *Workstation) float64 {
 		task_idx := tc.taskMapping[taskid]
 		total_cost += tc.tasks[task_idx].cost
 	}
-	return k_cycle_time - total_cost
+	return tc.cycleTime - total_cost
 }
 
 func IsSolutionValid(sol *PartialSolution) bool {
@@ -192,7 +327,11 @@ func IsSolutionValid(sol *PartialSolution) bool {
 
 	// find id of first workstation with no tasks
 	ws_end_id := ws_start_id
-	for len(wsc.workstations[ws_end_id].tasks) != 0 {
+	for {
+		ws, ok := wsc.workstations[ws_end_id]
+		if !ok || len(ws.tasks) == 0 {
+			break
+		}
 		ws_end_id++
 	}
 	if ws_end_id == ws_start_id {
@@ -200,135 +339,177 @@ func IsSolutionValid(sol *PartialSolution) bool {
 		return false
 	}
 
+	setups := sol.getStationSetups()
 	for i := ws_start_id; i < ws_end_id; i++ {
 		if len(wsc.workstations[i].tasks) == 0 {
 			fmt.Println("There should be no workstations in the solution range with no tasks")
 			return false
 		}
 
-		if wsc.workstations[i].GetCost() > k_cycle_time {
+		// Each side of a two-sided station and each operator of a multi-manned one
+		// has the cycle time, checked below
+		capacity := tc.cycleTime * float64(sol.GetReplicas(i))
+		scheduled := tc.layout == LayoutTwoSided || sol.operators != nil
+		if !scheduled && wsc.workstations[i].GetCost()+setups[i] > capacity {
 			fmt.Println("Cycle time exceed on workstation " + strconv.Itoa(int(i)))
 			return false
 		}
 	}
 
-	// Verify prereqs met
+	// Station of every task in the solution range, looked up when checking prereqs
+	station_of := make(map[TaskId_t]WorkstationId_t)
+	for i := ws_start_id; i < ws_end_id; i++ {
+		for _, taskid := range wsc.workstations[i].tasks {
+			station_of[taskid] = i
+		}
+	}
+
+	if zone := tc.brokenZone(station_of); zone != nil {
+		fmt.Println("Zone " + zone.ToStr() + " not met")
+		return false
+	}
+	if err := tc.checkPinned(station_of); err != nil {
+		fmt.Println(err.Error())
+		return false
+	}
+	if tc.HasSetups() && tc.layout == LayoutStraight {
+		if err := sol.checkSequence(tc); err != nil {
+			fmt.Println(err.Error())
+			return false
+		}
+	}
+
+	if tc.layout == LayoutTwoSided {
+		if err := sol.checkTwoSided(tc, station_of); err != nil {
+			fmt.Println(err.Error())
+			return false
+		}
+		return is_valid
+	}
+	if sol.operators != nil {
+		if err := sol.checkMultiManned(tc, station_of); err != nil {
+			fmt.Println(err.Error())
+			return false
+		}
+		return is_valid
+	}
+
+	// Verify prereqs met, on a U-line along the path of the product
 	for i, _ := range sol.assignments {
-		taskid := sol.assignments[i].i
-		wsid := sol.assignments[i].j
-		_task := tc.tasks[tc.taskMapping[taskid]]
-
-		for _, prereqid := range _task.prereqs {
-			found_prereq := false
-			for cur_ws_id := ws_start_id; cur_ws_id <= wsid; cur_ws_id++ {
-				if wsc.workstations[cur_ws_id].Contains(prereqid) {
-					found_prereq = true
-					break
-				}
-			}
-			if !found_prereq {
-				fmt.Println("Not all prereqs found for task " + _task.ToStr())
-				return false
-			}
+		_task := &tc.tasks[tc.taskMapping[sol.assignments[i].i]]
+		if err := sol.checkPrecedence(tc, station_of, ws_end_id, _task); err != nil {
+			fmt.Println(err.Error())
+			return false
 		}
 	}
 
 	return is_valid
 }
 
-func ComputeSolutionSST() *PartialSolution {
-	tc := GetTaskContainer()
-	wsc := GetWorkstationContainer()
-	unassigned := []*Task{}
-	sol := PartialSolution{}
+// Checks the solution against the tasks it was made for without using the global
+// workstation container. Every task must be assigned once, stations must be
+// numbered without gaps from the first task id, no station may exceed the cycle
+// time, no task may come before one of its prereqs, every zone must be met and
+// every pinned task must be at a station of its pin. With setup times a station
+// does its tasks in the order of the assignments, which has to follow the
+// prereqs on a straight line, and needs their setup times too. With a crew every
+// station needs its own worker, who can do its tasks in their times. At
+// multi-manned stations the tasks of each operator have to fit the cycle time
+// one after the other, each after its prereqs at the station.
+func (sol *PartialSolution) Validate() error {
+	tc := sol.getTaskContainer()
+	if len(tc.tasks) == 0 {
+		return nil
+	}
+	ws_start_id := WorkstationId_t(tc.tasks[0].id)
 
-	// Fill container of unassigned task ids
-	for i, _ := range tc.tasks {
-		unassigned = append(unassigned, &tc.tasks[i])
-	}
-
-	// Sort unassigned tasks ids on task time
-	sort.Slice(unassigned, func(i, j int) bool {
-		lhs_cost := unassigned[i].cost
-		rhs_cost := unassigned[j].cost
-		return lhs_cost < rhs_cost
-	})
-
-	// ############################################
-	// Assign tasks to stations using SST heuristic
-
-	// Problem writeup states that workstations and tasks start with common index
-	// The lowest id of workstation that can fit some unassigned task
-	min_ws_id := WorkstationId_t(tc.tasks[0].id)
-	// The highest id of workstations to check for met prereqs
-	max_ws_id := WorkstationId_t(tc.tasks[0].id)
-	// The current id of workstations targetted to fill
-	cur_ws_id := WorkstationId_t(tc.tasks[0].id)
-
-	// Assign tasks until there are none left unassigned
-	for len(unassigned) != 0 {
-		min_cost := unassigned[0].cost
-
-		// Increment the lowest workstation to check, if needed
-		for min_cost > workstationCapacityRemaining(wsc.workstations[min_ws_id]) {
-			min_ws_id++
-			if min_ws_id > max_ws_id {
-				max_ws_id++
-			}
+	station_of := make(map[TaskId_t]WorkstationId_t)
+	loads := make(map[WorkstationId_t]float64)
+	ws_end_id := ws_start_id
+	for _, asg := range sol.assignments {
+		task_idx, ok := tc.taskMapping[asg.i]
+		if !ok {
+			return errors.New("Unknown task " + strconv.Itoa(int(asg.i)) + " in solution")
 		}
-		cur_ws_id = min_ws_id
-
-		// There is at least one task that can be assigned in the workstation range
-		// given min_cost <= capacity(max_ws_id) enforced above
-
-		// Attempt to fill lowest workstations first
-		task_assigned := false
-		grew_workstations := false
-		for cur_ws_id <= max_ws_id {
-			cur_cap := workstationCapacityRemaining(wsc.workstations[cur_ws_id])
-
-			// Assign first task found that fits in the cur workstation if possible
-			for i, task := range unassigned {
-				// Assign task if conditions met
-				if task.cost <= cur_cap && sol.TaskPrereqsMet(task, cur_ws_id) {
-					// Add assignment to solution
-					assignment := TaskAssignment{task.id, cur_ws_id}
-					sol.assignments = append(sol.assignments, assignment)
-
-					// Add task to workstation
-					wstasks := &wsc.workstations[cur_ws_id].tasks
-					*wstasks = append(*wstasks, task.id)
-
-					// remove this taskid from unassigned, linear time, sad day.
-					unassigned = append(unassigned[:i], unassigned[i+1:]...)
-
-					task_assigned = true
-					break
-				}
-			}
+		if _, ok := station_of[asg.i]; ok {
+			return errors.New("Task " + strconv.Itoa(int(asg.i)) + " assigned more than once")
+		}
+		if asg.j < ws_start_id {
+			return errors.New("Workstation " + strconv.Itoa(int(asg.j)) + " is before the first workstation")
+		}
+		station_of[asg.i] = asg.j
+		loads[asg.j] += sol.taskTime(tc, task_idx, asg.j)
+		if asg.j >= ws_end_id {
+			ws_end_id = asg.j + 1
+		}
+	}
 
-			if task_assigned {
-				break
-			}
+	if len(station_of) != len(tc.tasks) {
+		return errors.New("Only " + strconv.Itoa(len(station_of)) + " of " +
+			strconv.Itoa(len(tc.tasks)) + " tasks assigned")
+	}
+	if len(loads) != int(ws_end_id-ws_start_id) {
+		return errors.New("There should be no workstations in the solution range with no tasks")
+	}
+
+	if zone := tc.brokenZone(station_of); zone != nil {
+		return errors.New("Zone " + zone.ToStr() + " not met")
+	}
+	if err := tc.checkPinned(station_of); err != nil {
+		return err
+	}
+	if tc.HasSetups() && tc.layout == LayoutStraight {
+		if err := sol.checkSequence(tc); err != nil {
+			return err
+		}
+	}
+	if sol.workers != nil {
+		if err := sol.checkWorkers(tc); err != nil {
+			return err
+		}
+	}
 
-			cur_ws_id++
+	if tc.layout == LayoutTwoSided {
+		return sol.checkTwoSided(tc, station_of)
+	}
+	if sol.operators != nil {
+		return sol.checkMultiManned(tc, station_of)
+	}
 
-			if cur_ws_id > max_ws_id && !grew_workstations {
-				max_ws_id++
-				grew_workstations = true
-			}
+	for wsid, setup := range sol.getStationSetups() {
+		loads[wsid] += setup
+	}
+	for wsid, load := range loads {
+		if load > tc.cycleTime*float64(sol.GetReplicas(wsid))+k_time_epsilon {
+			return errors.New("Cycle time exceed on workstation " + strconv.Itoa(int(wsid)))
 		}
-		if !grew_workstations && !task_assigned {
-			panic("There is was no task assigned, this should never happen")
+	}
+
+	for taskid, _ := range station_of {
+		_task := &tc.tasks[tc.taskMapping[taskid]]
+		if err := sol.checkPrecedence(tc, station_of, ws_end_id, _task); err != nil {
+			return err
 		}
 	}
 
-	// Validate solution found
-	if !IsSolutionValid(&sol) {
+	return nil
+}
+
+// Solves the tasks in the global container with the SST heuristic and stores the
+// station assignments in the global workstation container. The options may be nil.
+func ComputeSolutionSST(ctx context.Context, opts *SolveOptions) *SolveResult {
+	return ComputeSolution(ctx, SolveSST, opts)
+}
+
+// Like ComputeSolutionSST but with any solver
+func ComputeSolution(ctx context.Context, solver SolverFunc, opts *SolveOptions) *SolveResult {
+	result := solver(ctx, GetTaskContainer(), opts)
+	GetWorkstationContainer().FillFromSolution(result.sol)
+
+	// Validate solution found, an interrupted solve is partial so it can't pass
+	if result.status != SolveInterrupted && !IsSolutionValid(result.sol) {
 		fmt.Println("WARNING Invalid solution detected")
 	}
 
-	// Perform qualitative analysis on solution found
-
-	return &sol
+	return result
 }
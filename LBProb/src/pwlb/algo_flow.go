@@ -0,0 +1,289 @@
+package pwlb
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// Parameters of a flow simulation, the same parameters always give the same
+// result
+type FlowConfig struct {
+	Units   int   // number of units sent down the line
+	Buffers []int // room for units between each station and the next, one value for all or one per gap
+	Paced   bool  // the line moves every unit at once, see SimulateFlow
+	Seed    int64
+}
+
+// How one station of a solution spent the time of a flow simulation. Busy,
+// blocked and starved time add up to the time of the run for every copy of the
+// station.
+type StationFlow struct {
+	station  WorkstationId_t
+	replicas int
+	busy     float64 // time spent on units
+	blocked  float64 // time a finished unit waited for room after the station
+	starved  float64 // time the station waited for a unit
+}
+
+func (sf *StationFlow) GetStation() WorkstationId_t {
+	return sf.station
+}
+
+func (sf *StationFlow) GetBusy() float64 {
+	return sf.busy
+}
+
+func (sf *StationFlow) GetBlocked() float64 {
+	return sf.blocked
+}
+
+func (sf *StationFlow) GetStarved() float64 {
+	return sf.starved
+}
+
+// Outcome of a flow simulation of a solution, see SimulateFlow
+type FlowResult struct {
+	units     int
+	cycleTime float64
+	totalTime float64 // from the first unit entering the line to the last leaving it
+	unitTime  float64 // time the units spent in the line summed
+	stations  []StationFlow
+}
+
+func (fr *FlowResult) GetUnits() int {
+	return fr.units
+}
+
+func (fr *FlowResult) GetTotalTime() float64 {
+	return fr.totalTime
+}
+
+// Returns the result of each station by station id
+func (fr *FlowResult) GetStations() []StationFlow {
+	return fr.stations
+}
+
+// Returns the units finished per unit of time
+func (fr *FlowResult) GetThroughput() float64 {
+	if fr.totalTime == 0.0 {
+		return 0.0
+	}
+	return float64(fr.units) / fr.totalTime
+}
+
+// Returns the share of the throughput planned at the cycle time the line reaches
+func (fr *FlowResult) GetThroughputShare() float64 {
+	return fr.GetThroughput() * fr.cycleTime
+}
+
+// Returns the mean number of units in the line over the run, work in progress
+func (fr *FlowResult) GetMeanWIP() float64 {
+	if fr.totalTime == 0.0 {
+		return 0.0
+	}
+	return fr.unitTime / fr.totalTime
+}
+
+// Returns the share of the time of the run the station spent busy, blocked and
+// starved, each copy of a replicated station counted
+func (fr *FlowResult) GetShares(pos int) (busy, blocked, starved float64) {
+	sf := &fr.stations[pos]
+	time := fr.totalTime * float64(sf.replicas)
+	if time == 0.0 {
+		return 0.0, 0.0, 0.0
+	}
+	return sf.busy / time, sf.blocked / time, sf.starved / time
+}
+
+// Sends units one after another down the stations of the solution in order of
+// station id, drawing every task time of every unit from its distribution, and
+// tracks when each unit starts and leaves each station. Units are launched into
+// the first station every cycle time, or as soon as it is free after that, and
+// there is always room after the last station.
+//
+// On an unpaced line a station starts a unit as soon as it has one and is free,
+// and passes it on once there is room in the buffer after it or at the next
+// station, otherwise the station is blocked. A station with copies works on as
+// many units at once. On a paced line all units move at once every cycle time,
+// or once the slowest station is done when it overruns, and the buffers aren't
+// used. A paced station with copies gets their cycle time together, like in
+// Simulate, which also rejects the same lines.
+func SimulateFlow(sol *PartialSolution, cfg FlowConfig) (*FlowResult, error) {
+	if cfg.Units <= 0 {
+		return nil, errors.New("A flow simulation needs at least one unit")
+	}
+	tc := sol.getTaskContainer()
+	if err := sol.checkTimedAsWhole(tc); err != nil {
+		return nil, err
+	}
+	fr := &FlowResult{units: cfg.Units, cycleTime: tc.cycleTime}
+
+	// Stations by id with the container indices of their tasks
+	station_idxs := make(map[WorkstationId_t][]int)
+	for _, asg := range sol.assignments {
+		if _, ok := station_idxs[asg.j]; !ok {
+			fr.stations = append(fr.stations, StationFlow{station: asg.j})
+		}
+		station_idxs[asg.j] = append(station_idxs[asg.j], tc.taskMapping[asg.i])
+	}
+	sort.Slice(fr.stations, func(a, b int) bool {
+		return fr.stations[a].station < fr.stations[b].station
+	})
+	num_stations := len(fr.stations)
+	if num_stations == 0 {
+		return fr, nil
+	}
+	for i, _ := range fr.stations {
+		fr.stations[i].replicas = sol.GetReplicas(fr.stations[i].station)
+	}
+
+	buffers, err := getFlowBuffers(cfg.Buffers, num_stations)
+	if err != nil {
+		return nil, err
+	}
+
+	// Time each unit takes at each station, drawn unit by unit, with the setup
+	// times of the station
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	setups := sol.getStationSetups()
+	loads := make([][]float64, cfg.Units)
+	for n, _ := range loads {
+		loads[n] = make([]float64, num_stations)
+		for pos, _ := range fr.stations {
+			loads[n][pos] = setups[fr.stations[pos].station]
+			for _, idx := range station_idxs[fr.stations[pos].station] {
+				loads[n][pos] += tc.tasks[idx].sampleTime(rng)
+			}
+		}
+	}
+
+	if cfg.Paced {
+		fr.runPaced(loads)
+	} else {
+		fr.runUnpaced(loads, buffers)
+	}
+	return fr, nil
+}
+
+// Returns the buffer after each station but the last from the buffers given
+func getFlowBuffers(buffers []int, num_stations int) ([]int, error) {
+	gaps := make([]int, num_stations-1)
+	switch {
+	case len(buffers) == 0:
+	case len(buffers) == 1:
+		for i, _ := range gaps {
+			gaps[i] = buffers[0]
+		}
+	case len(buffers) == len(gaps):
+		copy(gaps, buffers)
+	default:
+		return nil, errors.New("Expected one buffer or one for each of the " + strconv.Itoa(len(gaps)) +
+			" gaps between stations, got " + strconv.Itoa(len(buffers)))
+	}
+	for _, size := range gaps {
+		if size < 0 {
+			return nil, errors.New("Buffer size " + strconv.Itoa(size) + " must be no less than zero")
+		}
+	}
+	return gaps, nil
+}
+
+// Runs the units through an unpaced line. Unit n starts at a station once it has
+// left the station before, or been launched at the first station, and a copy is
+// free, that is unit n-k with k copies has left. It leaves once there is room
+// after it, that is the unit as many places ahead as there are buffer places and
+// copies of the next station has left that station. Units keep their order
+// throughout.
+func (fr *FlowResult) runUnpaced(loads [][]float64, buffers []int) {
+	num_units, num_stations := len(loads), len(fr.stations)
+	start := make([][]float64, num_stations)
+	depart := make([][]float64, num_stations)
+	for pos, _ := range fr.stations {
+		start[pos] = make([]float64, num_units)
+		depart[pos] = make([]float64, num_units)
+	}
+
+	for n := 0; n < num_units; n++ {
+		for pos, _ := range fr.stations {
+			sf := &fr.stations[pos]
+			begin := float64(n) * fr.cycleTime
+			if pos != 0 {
+				begin = depart[pos-1][n]
+			}
+			if n >= sf.replicas {
+				begin = math.Max(begin, depart[pos][n-sf.replicas])
+			}
+			if n != 0 {
+				begin = math.Max(begin, start[pos][n-1])
+			}
+			finish := begin + loads[n][pos]
+
+			leave := finish
+			if n != 0 {
+				leave = math.Max(leave, depart[pos][n-1])
+			}
+			if pos != num_stations-1 {
+				if ahead := n - buffers[pos] - fr.stations[pos+1].replicas; ahead >= 0 {
+					leave = math.Max(leave, depart[pos+1][ahead])
+				}
+			}
+
+			start[pos][n], depart[pos][n] = begin, leave
+			sf.busy += loads[n][pos]
+			sf.blocked += leave - finish
+		}
+		fr.unitTime += depart[num_stations-1][n] - start[0][n]
+	}
+
+	fr.totalTime = depart[num_stations-1][num_units-1]
+	for i, _ := range fr.stations {
+		sf := &fr.stations[i]
+		sf.starved = fr.totalTime*float64(sf.replicas) - sf.busy - sf.blocked
+	}
+}
+
+// Runs the units through a paced line. In step s station pos works on unit
+// s-pos, the step lasts the cycle time or as long as the slowest station takes.
+func (fr *FlowResult) runPaced(loads [][]float64) {
+	num_units, num_stations := len(loads), len(fr.stations)
+	for step := 0; step < num_units+num_stations-1; step++ {
+		length := fr.cycleTime
+		in_line := 0
+		for pos, _ := range fr.stations {
+			if n := step - pos; n >= 0 && n < num_units {
+				length = math.Max(length, loads[n][pos]/float64(fr.stations[pos].replicas))
+				in_line++
+			}
+		}
+
+		for pos, _ := range fr.stations {
+			sf := &fr.stations[pos]
+			capacity := length * float64(sf.replicas)
+			if n := step - pos; n >= 0 && n < num_units {
+				sf.busy += loads[n][pos]
+				sf.blocked += capacity - loads[n][pos]
+			} else {
+				sf.starved += capacity
+			}
+		}
+		fr.totalTime += length
+		fr.unitTime += float64(in_line) * length
+	}
+}
+
+// Returns a line for each station like
+// "Station 2:      Utilization 88.2%   Blocked 3.1%   Starved 8.7%"
+func PrettyFlowStr(fr *FlowResult) string {
+	str := ""
+	for i, _ := range fr.stations {
+		busy, blocked, starved := fr.GetShares(i)
+		str += "Station " + strconv.Itoa(int(fr.stations[i].station)) + ":      "
+		str += "Utilization " + strconv.FormatFloat(busy*100.0, 'f', 1, 64) + "%"
+		str += "   Blocked " + strconv.FormatFloat(blocked*100.0, 'f', 1, 64) + "%"
+		str += "   Starved " + strconv.FormatFloat(starved*100.0, 'f', 1, 64) + "%\n"
+	}
+	return str
+}
@@ -0,0 +1,141 @@
+package pwlb
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+)
+
+// Shape of the task times in a generated spec
+type TimeDistribution int
+
+const (
+	TimesUniform      TimeDistribution = iota // spread evenly up to half the cycle time
+	TimesBimodal                              // mostly short tasks with a second peak of long ones
+	TimesPeakAtBottom                         // mostly short tasks with a tail of longer ones
+)
+
+var time_distribution_names = map[string]TimeDistribution{
+	"uniform":        TimesUniform,
+	"bimodal":        TimesBimodal,
+	"peak-at-bottom": TimesPeakAtBottom,
+}
+
+func ParseTimeDistribution(name string) (TimeDistribution, error) {
+	dist, ok := time_distribution_names[name]
+	if !ok {
+		return TimesUniform, errors.New("Unknown task time distribution " + name +
+			", expected uniform, bimodal or peak-at-bottom")
+	}
+	return dist, nil
+}
+
+// Parameters of a generated spec, the same parameters always give the same spec
+type GeneratorConfig struct {
+	NumTasks      int
+	OrderStrength float64 // target share of task pairs ordered by precedence, 0 to 1
+	Times         TimeDistribution
+	CycleTime     float64 // no task time is larger than this
+	Seed          int64
+}
+
+// Generates a random spec. Tasks get ids from 1 and every prereq is listed
+// before the task needing it. Precedences are added between random pairs of
+// tasks until the order strength reaches the target, then redundant prereqs are
+// removed so the spec only lists the direct ones.
+func GenerateSpec(cfg GeneratorConfig) ([]string, error) {
+	if cfg.NumTasks <= 0 {
+		return nil, errors.New("A generated spec needs at least one task")
+	}
+	if cfg.OrderStrength < 0.0 || cfg.OrderStrength > 1.0 {
+		return nil, errors.New("Order strength must be between 0 and 1")
+	}
+	if cfg.CycleTime <= 0.0 {
+		return nil, errors.New("Cycle time must be positive")
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+
+	gen := NewTaskContainer()
+	gen.SetCycleTime(cfg.CycleTime)
+	for i := 0; i < cfg.NumTasks; i++ {
+		new_task := NewTask(TaskId_t(i+1), generateTaskTime(rng, cfg.Times, cfg.CycleTime))
+		gen.taskMapping[new_task.id] = len(gen.tasks)
+		gen.tasks = append(gen.tasks, *new_task)
+	}
+
+	addRandomPrereqs(gen, rng, cfg.OrderStrength)
+
+	return gen.ToReducedStrArr(), nil
+}
+
+// Draws a task time rounded to one decimal place between 0.1 and the cycle time
+func generateTaskTime(rng *rand.Rand, dist TimeDistribution, cycle_time float64) float64 {
+	var task_time float64
+	switch dist {
+	case TimesUniform:
+		task_time = rng.Float64() * 0.5 * cycle_time
+	case TimesBimodal:
+		if rng.Float64() < 0.8 {
+			task_time = (0.1 + 0.05*rng.NormFloat64()) * cycle_time
+		} else {
+			task_time = (0.6 + 0.1*rng.NormFloat64()) * cycle_time
+		}
+	case TimesPeakAtBottom:
+		task_time = math.Abs(0.1*rng.NormFloat64()) * cycle_time
+	}
+
+	task_time = math.Round(task_time*10.0) / 10.0
+	return math.Max(0.1, math.Min(task_time, math.Floor(cycle_time*10.0)/10.0))
+}
+
+// Adds prereqs between random pairs of tasks, the earlier listed task of a pair
+// being the prereq, until the share of ordered pairs reaches the target. The
+// closure is kept up to date with bitsets as links are added.
+func addRandomPrereqs(gen *TaskContainer, rng *rand.Rand, order_strength float64) {
+	num_tasks := len(gen.tasks)
+	if num_tasks < 2 {
+		return
+	}
+	target_pairs := int(math.Ceil(order_strength * float64(num_tasks*(num_tasks-1)/2)))
+
+	predecessors := make([]taskBitset, num_tasks)
+	followers := make([]taskBitset, num_tasks)
+	for i := 0; i < num_tasks; i++ {
+		predecessors[i] = newTaskBitset(num_tasks)
+		followers[i] = newTaskBitset(num_tasks)
+	}
+
+	num_pairs := 0
+	for num_pairs < target_pairs {
+		// Favor nearby pairs so the graph grows chains rather than one wide fan
+		lhs := rng.Intn(num_tasks - 1)
+		span := 1 + rng.Intn(num_tasks-1-lhs)
+		if rng.Intn(2) == 0 && span > 10 {
+			span = 1 + rng.Intn(10)
+		}
+		rhs := lhs + span
+
+		if followers[lhs].has(rhs) {
+			continue
+		}
+		gen.tasks[rhs].prereqs = append(gen.tasks[rhs].prereqs, gen.tasks[lhs].id)
+
+		// Everything up to and including lhs now comes before everything from rhs on
+		before := newTaskBitset(num_tasks)
+		before.unionWith(predecessors[lhs])
+		before.set(lhs)
+		after := newTaskBitset(num_tasks)
+		after.unionWith(followers[rhs])
+		after.set(rhs)
+
+		before.forEach(func(idx int) {
+			num_pairs -= followers[idx].count()
+			followers[idx].unionWith(after)
+			num_pairs += followers[idx].count()
+		})
+		after.forEach(func(idx int) {
+			predecessors[idx].unionWith(before)
+		})
+	}
+}
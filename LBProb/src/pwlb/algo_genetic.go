@@ -0,0 +1,138 @@
+package pwlb
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+)
+
+// Individuals of each generation of the genetic algorithm
+const k_genetic_population = 20
+
+// Generations of the genetic algorithm, fewer on large specs, see searchSteps
+const k_genetic_generations = 100
+
+// Spread of the noise added to the positional weights of the first generation
+const k_genetic_noise = 0.1
+
+// Priorities of the tasks decoded into a solution with its score
+type individual struct {
+	priorities []float64
+	sol        *PartialSolution
+	score      float64
+}
+
+// Assigns the tasks in the container to stations with a genetic algorithm over
+// the priorities of the tasks, each decoded into a solution by filling stations
+// like RPW with the available task of the highest priority first, see
+// SolveAnnealing. The first generation holds the positional weights, so the RPW
+// solution, priorities putting shorter tasks first like SST, and the positional
+// weights with random noise. Each next generation keeps the best individual and
+// is filled with children of parents picked by tournaments of two. A child takes
+// the priority of each task from either parent and each of its priorities is
+// replaced by a random one with a chance of one in the number of tasks.
+// Individuals are ranked by their score, see priorityScore, and the best
+// solution seen is returned. The global containers are not used.
+//
+// The same seed is used on every solve so the result is repeatable. The search
+// ends early like SolveAnnealing. An observer gets an iteration for every
+// generation and a solution for every better one found.
+func SolveGenetic(ctx context.Context, tc *TaskContainer, opts *SolveOptions) *SolveResult {
+	if tc.hasTogetherZones() {
+		return solveZoned(ctx, tc, opts, SolveGenetic)
+	}
+	if len(tc.tasks) == 0 {
+		return newCompletedResult(&PartialSolution{tc: tc})
+	}
+
+	progress := newSolveProgress(opts)
+	progress.bound(tc.GetLowerBound())
+
+	pd := newPriorityDecoder(tc, opts)
+	rng := rand.New(rand.NewSource(k_priority_seed))
+	num_tasks := len(tc.tasks)
+
+	// RPW is decoded without recovering so a spec no solver can solve panics
+	weights := pd.weights()
+	rpw_sol := pd.decode(ctx, weights)
+	if rpw_sol == nil {
+		return &SolveResult{&PartialSolution{tc: tc}, SolveInterrupted}
+	}
+	best := &individual{weights, rpw_sol, priorityScore(rpw_sol)}
+	progress.incumbent(best.sol)
+
+	sst := make([]float64, num_tasks)
+	for i, _ := range tc.tasks {
+		sst[i] = 1.0 / (1.0 + tc.tasks[i].cost)
+	}
+
+	// Priorities that leave a task no station are dropped, so a generation may
+	// have fewer individuals
+	population := []*individual{best}
+	for num := 1; num < k_genetic_population; num++ {
+		priorities := sst
+		if num > 1 {
+			priorities = make([]float64, num_tasks)
+			for i, _ := range priorities {
+				priorities[i] = weights[i] + k_genetic_noise*rng.NormFloat64()
+			}
+		}
+		if sol := pd.tryDecode(ctx, priorities); sol != nil {
+			population = append(population, &individual{priorities, sol, priorityScore(sol)})
+		}
+	}
+
+	generations := pd.searchSteps(k_genetic_generations)
+	for generation := 0; generation < generations; generation++ {
+		sort.SliceStable(population, func(a, b int) bool {
+			return population[a].score < population[b].score
+		})
+		if population[0].score < best.score {
+			best = population[0]
+			progress.incumbent(best.sol)
+		}
+		if priorityDone(ctx, progress, best.sol) {
+			break
+		}
+		progress.iteration()
+
+		next := []*individual{best}
+		for num := 1; num < k_genetic_population; num++ {
+			lhs, rhs := tournament(rng, population), tournament(rng, population)
+			child := make([]float64, num_tasks)
+			for i, _ := range child {
+				child[i] = lhs.priorities[i]
+				if rng.Intn(2) == 0 {
+					child[i] = rhs.priorities[i]
+				}
+				if rng.Intn(num_tasks) == 0 {
+					child[i] = rng.Float64()
+				}
+			}
+			if sol := pd.tryDecode(ctx, child); sol != nil {
+				next = append(next, &individual{child, sol, priorityScore(sol)})
+			}
+		}
+		population = next
+	}
+	for _, ind := range population {
+		if ind.score < best.score {
+			best = ind
+			progress.incumbent(best.sol)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return &SolveResult{best.sol, SolveInterrupted}
+	}
+	return newCompletedResult(best.sol)
+}
+
+// Returns the better scoring of two individuals picked at random
+func tournament(rng *rand.Rand, population []*individual) *individual {
+	lhs, rhs := population[rng.Intn(len(population))], population[rng.Intn(len(population))]
+	if rhs.score < lhs.score {
+		return rhs
+	}
+	return lhs
+}
@@ -0,0 +1,465 @@
+package pwlb
+
+import (
+	"errors"
+	"math/bits"
+	"strconv"
+)
+
+///////////////////////////////////
+// Bitset of task indices
+///////////////////////////////////
+
+// A set of container indices of tasks, one bit per task
+type taskBitset []uint64
+
+func newTaskBitset(num_tasks int) taskBitset {
+	return make(taskBitset, (num_tasks+63)/64)
+}
+
+func (b taskBitset) set(idx int) {
+	b[idx/64] |= 1 << uint(idx%64)
+}
+
+func (b taskBitset) has(idx int) bool {
+	return b[idx/64]&(1<<uint(idx%64)) != 0
+}
+
+func (b taskBitset) unionWith(other taskBitset) {
+	for i, word := range other {
+		b[i] |= word
+	}
+}
+
+func (b taskBitset) count() int {
+	num := 0
+	for _, word := range b {
+		num += bits.OnesCount64(word)
+	}
+	return num
+}
+
+// Calls fn with every index in the set in increasing order
+func (b taskBitset) forEach(fn func(idx int)) {
+	for i, word := range b {
+		for word != 0 {
+			fn(i*64 + bits.TrailingZeros64(word))
+			word &= word - 1
+		}
+	}
+}
+
+///////////////////////////////////
+// TaskGraph
+///////////////////////////////////
+
+// The precedence graph of the tasks in a container stored as adjacency lists of
+// container indices. The transitive closure and longest paths are computed the
+// first time they are queried and kept, so heuristics never walk the graph again
+// and solvers that only need direct links don't pay for the closure.
+type TaskGraph struct {
+	tc *TaskContainer
+
+	prereqs   [][]int // direct prereqs of each task
+	postreqs  [][]int // tasks that directly require each task
+	topoOrder []int
+
+	// Transitive closure in both directions, a task is in neither of its own sets
+	predecessors []taskBitset
+	followers    []taskBitset
+
+	// Sum of the task times of all predecessors and of all followers
+	predecessorsTime []float64
+	followersTime    []float64
+
+	// Longest path starting at each task by number of edges and by task time
+	// including the task itself, next holds the following index on the path or -1
+	height     []int
+	heightNext []int
+	tailTime   []float64
+	tailNext   []int
+}
+
+// Builds the precedence graph of the tasks in the container. Fails if a prereq
+// names a task that is not in the container or if the prereqs form a cycle.
+func NewTaskGraph(tc *TaskContainer) (*TaskGraph, error) {
+	num_tasks := len(tc.tasks)
+	g := &TaskGraph{tc: tc}
+	g.prereqs = make([][]int, num_tasks)
+	g.postreqs = make([][]int, num_tasks)
+
+	for i, _ := range tc.tasks {
+		for _, prereqid := range tc.tasks[i].prereqs {
+			prereq_idx, ok := tc.taskMapping[prereqid]
+			if !ok {
+				return nil, errors.New("Unknown prereq " + strconv.Itoa(int(prereqid)) +
+					" in task " + tc.tasks[i].ToStr())
+			}
+			g.prereqs[i] = append(g.prereqs[i], prereq_idx)
+			g.postreqs[prereq_idx] = append(g.postreqs[prereq_idx], i)
+		}
+	}
+
+	if err := g.buildTopoOrder(); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Kahn's algorithm, ties are broken by container index so the order is stable
+func (g *TaskGraph) buildTopoOrder() error {
+	num_tasks := len(g.prereqs)
+	indegree := make([]int, num_tasks)
+	for i, _ := range g.prereqs {
+		indegree[i] = len(g.prereqs[i])
+	}
+
+	var ready []int
+	for i := 0; i < num_tasks; i++ {
+		if indegree[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	for len(ready) != 0 {
+		idx := ready[0]
+		ready = ready[1:]
+		g.topoOrder = append(g.topoOrder, idx)
+
+		for _, post_idx := range g.postreqs[idx] {
+			indegree[post_idx]--
+			if indegree[post_idx] == 0 {
+				ready = append(ready, post_idx)
+			}
+		}
+	}
+
+	if len(g.topoOrder) != num_tasks {
+		for i, _ := range indegree {
+			if indegree[i] != 0 {
+				return errors.New("Prereq cycle found through task " + g.tc.tasks[i].ToStr())
+			}
+		}
+	}
+	return nil
+}
+
+func (g *TaskGraph) buildClosure() {
+	if g.predecessors != nil {
+		return
+	}
+
+	num_tasks := len(g.topoOrder)
+	g.predecessors = make([]taskBitset, num_tasks)
+	g.followers = make([]taskBitset, num_tasks)
+	g.predecessorsTime = make([]float64, num_tasks)
+	g.followersTime = make([]float64, num_tasks)
+
+	for _, idx := range g.topoOrder {
+		g.predecessors[idx] = newTaskBitset(num_tasks)
+		for _, prereq_idx := range g.prereqs[idx] {
+			g.predecessors[idx].set(prereq_idx)
+			g.predecessors[idx].unionWith(g.predecessors[prereq_idx])
+		}
+	}
+
+	for i := num_tasks - 1; i >= 0; i-- {
+		idx := g.topoOrder[i]
+		g.followers[idx] = newTaskBitset(num_tasks)
+		for _, post_idx := range g.postreqs[idx] {
+			g.followers[idx].set(post_idx)
+			g.followers[idx].unionWith(g.followers[post_idx])
+		}
+	}
+
+	for idx := 0; idx < num_tasks; idx++ {
+		g.predecessors[idx].forEach(func(other int) {
+			g.predecessorsTime[idx] += g.tc.tasks[other].cost
+		})
+		g.followers[idx].forEach(func(other int) {
+			g.followersTime[idx] += g.tc.tasks[other].cost
+		})
+	}
+}
+
+func (g *TaskGraph) buildLongestPaths() {
+	if g.height != nil {
+		return
+	}
+
+	num_tasks := len(g.topoOrder)
+	g.height = make([]int, num_tasks)
+	g.heightNext = make([]int, num_tasks)
+	g.tailTime = make([]float64, num_tasks)
+	g.tailNext = make([]int, num_tasks)
+
+	// Followers come later in the order so their paths are known first. The first
+	// longest branch in prereq listing order wins ties.
+	for i := num_tasks - 1; i >= 0; i-- {
+		idx := g.topoOrder[i]
+		g.heightNext[idx] = -1
+		g.tailNext[idx] = -1
+
+		max_tail := 0.0
+		for _, post_idx := range g.postreqs[idx] {
+			if g.heightNext[idx] == -1 || g.height[post_idx]+1 > g.height[idx] {
+				g.height[idx] = g.height[post_idx] + 1
+				g.heightNext[idx] = post_idx
+			}
+			if g.tailNext[idx] == -1 || g.tailTime[post_idx] > max_tail {
+				max_tail = g.tailTime[post_idx]
+				g.tailNext[idx] = post_idx
+			}
+		}
+		g.tailTime[idx] = g.tc.tasks[idx].cost + max_tail
+	}
+}
+
+func (g *TaskGraph) idxToIds(idxs []int) []TaskId_t {
+	ids := make([]TaskId_t, 0, len(idxs))
+	for _, idx := range idxs {
+		ids = append(ids, g.tc.tasks[idx].id)
+	}
+	return ids
+}
+
+func (g *TaskGraph) bitsetToIds(b taskBitset) []TaskId_t {
+	ids := make([]TaskId_t, 0, b.count())
+	b.forEach(func(idx int) {
+		ids = append(ids, g.tc.tasks[idx].id)
+	})
+	return ids
+}
+
+func (g *TaskGraph) followPath(idx int, next []int) []TaskId_t {
+	var path []TaskId_t
+	for ; idx != -1; idx = next[idx] {
+		path = append(path, g.tc.tasks[idx].id)
+	}
+	return path
+}
+
+func (g *TaskGraph) NumTasks() int {
+	return len(g.topoOrder)
+}
+
+// Returns all task ids ordered so every task comes after its prereqs
+func (g *TaskGraph) TopologicalOrder() []TaskId_t {
+	return g.idxToIds(g.topoOrder)
+}
+
+func (g *TaskGraph) DirectPrereqs(id TaskId_t) []TaskId_t {
+	return g.idxToIds(g.prereqs[g.tc.taskMapping[id]])
+}
+
+func (g *TaskGraph) DirectPostreqs(id TaskId_t) []TaskId_t {
+	return g.idxToIds(g.postreqs[g.tc.taskMapping[id]])
+}
+
+// Returns every task that must be done before the given one, in container order
+func (g *TaskGraph) Predecessors(id TaskId_t) []TaskId_t {
+	g.buildClosure()
+	return g.bitsetToIds(g.predecessors[g.tc.taskMapping[id]])
+}
+
+// Returns every task that can only be done after the given one, in container order
+func (g *TaskGraph) Followers(id TaskId_t) []TaskId_t {
+	g.buildClosure()
+	return g.bitsetToIds(g.followers[g.tc.taskMapping[id]])
+}
+
+func (g *TaskGraph) NumPredecessors(id TaskId_t) int {
+	g.buildClosure()
+	return g.predecessors[g.tc.taskMapping[id]].count()
+}
+
+func (g *TaskGraph) NumFollowers(id TaskId_t) int {
+	g.buildClosure()
+	return g.followers[g.tc.taskMapping[id]].count()
+}
+
+// Returns true if lhs must be done before rhs, directly or through other tasks
+func (g *TaskGraph) IsPredecessor(lhs, rhs TaskId_t) bool {
+	g.buildClosure()
+	return g.predecessors[g.tc.taskMapping[rhs]].has(g.tc.taskMapping[lhs])
+}
+
+// Returns the sum of the task times of every predecessor of the task
+func (g *TaskGraph) PredecessorsTime(id TaskId_t) float64 {
+	g.buildClosure()
+	return g.predecessorsTime[g.tc.taskMapping[id]]
+}
+
+// Returns the sum of the task times of every follower of the task
+func (g *TaskGraph) FollowersTime(id TaskId_t) float64 {
+	g.buildClosure()
+	return g.followersTime[g.tc.taskMapping[id]]
+}
+
+// Returns the task time plus the task times of all its followers
+func (g *TaskGraph) PositionalWeight(id TaskId_t) float64 {
+	g.buildClosure()
+	idx := g.tc.taskMapping[id]
+	return g.tc.tasks[idx].cost + g.followersTime[idx]
+}
+
+// Returns the number of edges on the longest path of followers starting at the
+// task along with the path itself
+func (g *TaskGraph) HeightWithPath(id TaskId_t) (int, []TaskId_t) {
+	g.buildLongestPaths()
+	idx := g.tc.taskMapping[id]
+	return g.height[idx], g.followPath(idx, g.heightNext)
+}
+
+// Like HeightWithPath but measures a path by the sum of its task times rather
+// than by the number of edges. The time includes the starting task.
+func (g *TaskGraph) TimeWithPath(id TaskId_t) (float64, []TaskId_t) {
+	g.buildLongestPaths()
+	idx := g.tc.taskMapping[id]
+	return g.tailTime[idx], g.followPath(idx, g.tailNext)
+}
+
+// Returns the number of edges on the longest path in the graph and the path
+func (g *TaskGraph) MaxHeightWithPath() (int, []TaskId_t) {
+	g.buildLongestPaths()
+	best_idx := -1
+	for idx, _ := range g.height {
+		if best_idx == -1 || g.height[idx] > g.height[best_idx] {
+			best_idx = idx
+		}
+	}
+	if best_idx == -1 {
+		return 0, nil
+	}
+	return g.height[best_idx], g.followPath(best_idx, g.heightNext)
+}
+
+// Returns the time of the most time consuming path in the graph and the path
+func (g *TaskGraph) CriticalPath() (float64, []TaskId_t) {
+	g.buildLongestPaths()
+	best_idx := -1
+	for idx, _ := range g.tailTime {
+		if best_idx == -1 || g.tailTime[idx] > g.tailTime[best_idx] {
+			best_idx = idx
+		}
+	}
+	if best_idx == -1 {
+		return 0.0, nil
+	}
+	return g.tailTime[best_idx], g.followPath(best_idx, g.tailNext)
+}
+
+// Returns the number of task pairs ordered by precedence, directly or not
+func (g *TaskGraph) NumOrderedPairs() int {
+	g.buildClosure()
+	num := 0
+	for _, preds := range g.predecessors {
+		num += preds.count()
+	}
+	return num
+}
+
+///////////////////////////////////
+// Transitive reduction
+///////////////////////////////////
+
+// A prereq listed for a task that is already implied by another of its prereqs
+type RedundantPrereq struct {
+	task      TaskId_t
+	prereq    TaskId_t
+	impliedBy TaskId_t // same as prereq when the prereq is listed twice
+}
+
+func (rp *RedundantPrereq) GetTaskId() TaskId_t {
+	return rp.task
+}
+
+func (rp *RedundantPrereq) GetPrereq() TaskId_t {
+	return rp.prereq
+}
+
+func (rp *RedundantPrereq) GetImpliedBy() TaskId_t {
+	return rp.impliedBy
+}
+
+// Returns a redundant prereq as a string like "4:0<1" meaning prereq 0 of task 4
+// is implied by prereq 1
+func (rp *RedundantPrereq) ToStr() string {
+	str := strconv.Itoa(int(rp.task)) + ":" + strconv.Itoa(int(rp.prereq))
+	str += "<" + strconv.Itoa(int(rp.impliedBy))
+	return str
+}
+
+// Returns the index of a task implying the i-th listed prereq of the task at idx,
+// or -1 if the prereq is needed. Only the later listing of a duplicate is implied.
+func (g *TaskGraph) findImpliedBy(idx, i int) int {
+	g.buildClosure()
+	prereqs := g.prereqs[idx]
+	for j, other_idx := range prereqs {
+		if j == i {
+			continue
+		}
+		if other_idx == prereqs[i] && j < i {
+			return other_idx
+		}
+		if g.predecessors[other_idx].has(prereqs[i]) {
+			return other_idx
+		}
+	}
+	return -1
+}
+
+// Finds every listed prereq that can be dropped without changing the order of
+// tasks, these are the edges removed by a transitive reduction of the graph.
+// Results are in container order of the tasks then listing order of the prereqs.
+func (g *TaskGraph) GetRedundantPrereqs() []RedundantPrereq {
+	g.buildClosure()
+	var redundant []RedundantPrereq
+	for idx, prereqs := range g.prereqs {
+		for i, prereq_idx := range prereqs {
+			if implied_by := g.findImpliedBy(idx, i); implied_by != -1 {
+				redundant = append(redundant, RedundantPrereq{
+					task:      g.tc.tasks[idx].id,
+					prereq:    g.tc.tasks[prereq_idx].id,
+					impliedBy: g.tc.tasks[implied_by].id,
+				})
+			}
+		}
+	}
+	return redundant
+}
+
+// Returns the tasks as spec lines with all redundant prereqs left out
+func (tc *TaskContainer) ToReducedStrArr() []string {
+	graph := tc.GetGraph()
+
+	var strs []string
+	for idx, _ := range tc.tasks {
+		reduced := tc.tasks[idx]
+		reduced.prereqs = nil
+		for i, prereqid := range tc.tasks[idx].prereqs {
+			if graph.findImpliedBy(idx, i) == -1 {
+				reduced.prereqs = append(reduced.prereqs, prereqid)
+			}
+		}
+		strs = append(strs, reduced.ToStr())
+	}
+	return append(strs, tc.directivesToStrArr()...)
+}
+
+func PrettyRedundantPrereqsStr(redundant []RedundantPrereq) string {
+	str := ""
+	for i, _ := range redundant {
+		rp := &redundant[i]
+		str += "Task " + strconv.Itoa(int(rp.task)) + ":      "
+		str += "Prereq " + strconv.Itoa(int(rp.prereq))
+		if rp.impliedBy == rp.prereq {
+			str += "   Listed twice"
+		} else {
+			str += "   Implied by " + strconv.Itoa(int(rp.impliedBy))
+		}
+		str += "\n"
+	}
+	return str
+}
@@ -0,0 +1,370 @@
+package pwlb
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Width of the bar of each operator in a schedule, spanning the cycle time
+const k_schedule_width = 40
+
+// Returns the operator of a multi-manned station who does the task, counted from
+// one, zero when the stations aren't multi-manned
+func (sol *PartialSolution) GetOperator(id TaskId_t) int {
+	return sol.operators[id]
+}
+
+// Returns the number of operators with tasks at the station, one for a station
+// that isn't multi-manned
+func (sol *PartialSolution) GetStationOperators(wsid WorkstationId_t) int {
+	num_operators := 0
+	for key, _ := range sol.getOperatorLoads() {
+		if key.ws == wsid {
+			num_operators++
+		}
+	}
+	return num_operators
+}
+
+// Returns the number of operators with tasks over all stations, each side of a
+// two-sided station and each operator of a multi-manned station counting as one
+func (sol *PartialSolution) GetOperatorCount() int {
+	return len(sol.getOperatorLoads())
+}
+
+// Checks the operators and start times of a complete solution with multi-manned
+// stations. Every task needs an operator, the tasks of an operator must not
+// overlap or run past the cycle time and a task may only start after its
+// prereqs at the same station have finished.
+func (sol *PartialSolution) checkMultiManned(tc *TaskContainer, station_of map[TaskId_t]WorkstationId_t) error {
+	by_operator := make(map[operatorKey][]scheduledTask)
+
+	for taskid, wsid := range station_of {
+		_task := &tc.tasks[tc.taskMapping[taskid]]
+		op := sol.operators[taskid]
+		if op < 1 {
+			return errors.New("Task " + _task.ToStr() + " has no operator")
+		}
+
+		scheduled, err := sol.scheduleTask(tc, station_of, _task)
+		if err != nil {
+			return err
+		}
+		key := operatorKey{ws: wsid, op: op}
+		by_operator[key] = append(by_operator[key], scheduled)
+	}
+
+	return checkOverlaps(by_operator)
+}
+
+///////////////////////////////////
+// Multi-manned solve
+///////////////////////////////////
+
+// Assigns the tasks of the container to stations where up to max_operators
+// operators work on the product at once, like on a bus or an aircraft section,
+// using as few operators as it can and then as few stations. Each operator has
+// the cycle time and a task starts once its operator is free and its prereqs at
+// the same station have finished, so precedence can leave an operator idle.
+//
+// Stations are filled one at a time. For each number of operators up to a limit
+// the station is filled by ranked positional weight, each task going to the
+// operator where it starts first, and the number of operators that keeps them
+// busiest is used, the larger on a tie. The line is filled with every limit up
+// to max_operators, and once more with the limit at every station, and the best
+// line is kept. It is optimal when its operators meet the lower bound on
+// operators and its stations the fewest those operators need.
+//
+// If the context ends the best line so far is returned as interrupted, which is
+// partial when no line was finished. Fails when max_operators is below one, the
+// container isn't a straight line, has zones, pins, setup times or workers, or a
+// task exceeds the cycle time.
+func SolveMultiManned(ctx context.Context, tc *TaskContainer, max_operators int) (*SolveResult, error) {
+	if max_operators < 1 {
+		return nil, errors.New("A station needs at least one operator")
+	}
+	if tc.layout != LayoutStraight {
+		return nil, errors.New("Multi-manned stations can only be on a straight line")
+	}
+	pinned := false
+	for i, _ := range tc.tasks {
+		pinned = pinned || tc.tasks[i].pin != nil
+		if tc.tasks[i].cost > tc.cycleTime+k_time_epsilon {
+			return nil, errors.New("Task " + tc.tasks[i].ToStr() + " exceeds the cycle time")
+		}
+	}
+	if len(tc.zones) != 0 || pinned || tc.HasSetups() || len(tc.workers) != 0 {
+		return nil, errors.New("Multi-manned stations can't have zones, pins, setup times or workers")
+	}
+	if len(tc.tasks) == 0 {
+		return &SolveResult{newMannedSolution(tc), SolveOptimal}, nil
+	}
+
+	ms := &mannedSearch{tc: tc, graph: tc.GetGraph(), less: rpwLess(tc)}
+	var best *PartialSolution
+	for limit := 1; limit <= max_operators; limit++ {
+		for _, fixed := range []bool{false, true} {
+			if fixed && limit == 1 {
+				continue
+			}
+			if ctx.Err() != nil {
+				if best == nil {
+					best = newMannedSolution(tc)
+				}
+				return &SolveResult{best, SolveInterrupted}, nil
+			}
+
+			sol := ms.fill(limit, fixed)
+			if best == nil || mannedBetter(sol, best) {
+				best = sol
+			}
+		}
+	}
+
+	num_operators := best.GetOperatorCount()
+	if num_operators <= tc.GetLowerBound() &&
+		best.GetMeasuredMin() <= (num_operators+max_operators-1)/max_operators {
+		return &SolveResult{best, SolveOptimal}, nil
+	}
+	return &SolveResult{best, SolveCompleted}, nil
+}
+
+func newMannedSolution(tc *TaskContainer) *PartialSolution {
+	return &PartialSolution{tc: tc, starts: make(map[TaskId_t]float64),
+		operators: make(map[TaskId_t]int)}
+}
+
+// Returns true if the solution has fewer operators than the other, or as many
+// and fewer stations
+func mannedBetter(sol, other *PartialSolution) bool {
+	if sol.GetOperatorCount() != other.GetOperatorCount() {
+		return sol.GetOperatorCount() < other.GetOperatorCount()
+	}
+	return sol.GetMeasuredMin() < other.GetMeasuredMin()
+}
+
+// State of SolveMultiManned, fixed over the lines it fills
+type mannedSearch struct {
+	tc    *TaskContainer
+	graph *TaskGraph
+	less  func(lhs, rhs int) bool // ranked positional weight, larger first
+}
+
+// A task at the station being filled with its operator, counted from zero, and
+// start time
+type mannedTask struct {
+	idx   int
+	op    int
+	start float64
+}
+
+// Returns a line with at most limit operators at each station, exactly limit
+// when fixed unless the station runs out of tasks
+func (ms *mannedSearch) fill(limit int, fixed bool) *PartialSolution {
+	tc := ms.tc
+	num_tasks := len(tc.tasks)
+	sol := newMannedSolution(tc)
+	indegree := make([]int, num_tasks)
+	for i, _ := range tc.tasks {
+		indegree[i] = len(ms.graph.prereqs[i])
+	}
+
+	wsid := WorkstationId_t(tc.tasks[0].id)
+	for len(sol.assignments) != num_tasks {
+		first := 1
+		if fixed {
+			first = limit
+		}
+
+		// The number of operators whose time goes most to tasks
+		var best []mannedTask
+		best_use, best_work := 0.0, 0.0
+		for num_operators := first; num_operators <= limit; num_operators++ {
+			placed, work, used := ms.fillStation(num_operators, indegree)
+			if len(placed) == 0 {
+				continue
+			}
+			use := work / (float64(used) * tc.cycleTime)
+			if best == nil || use > best_use+k_time_epsilon ||
+				(use > best_use-k_time_epsilon && work > best_work+k_time_epsilon) {
+				best, best_use, best_work = placed, use, work
+			}
+		}
+		if best == nil {
+			panic("No task fits an empty multi-manned station")
+		}
+
+		// Operators are numbered in the order they get their first task
+		numbers := make(map[int]int)
+		for _, task := range best {
+			if _, ok := numbers[task.op]; !ok {
+				numbers[task.op] = len(numbers) + 1
+			}
+			id := tc.tasks[task.idx].id
+			sol.assignments = append(sol.assignments, TaskAssignment{id, wsid})
+			sol.operators[id] = numbers[task.op]
+			sol.starts[id] = task.start
+			indegree[task.idx] = -1
+			for _, post_idx := range ms.graph.postreqs[task.idx] {
+				indegree[post_idx]--
+			}
+		}
+		wsid++
+	}
+	return sol
+}
+
+// Returns the tasks a station with the number of operators would take, in the
+// order they are taken, with their times summed and the number of operators
+// given a task. Tasks with no unassigned prereqs, marked by an indegree of zero,
+// are available, assigned ones have -1.
+func (ms *mannedSearch) fillStation(num_operators int, indegree []int) ([]mannedTask, float64, int) {
+	tc := ms.tc
+	local := make([]int, len(indegree))
+	copy(local, indegree)
+
+	var avail []int
+	for i, _ := range local {
+		if local[i] == 0 {
+			avail = append(avail, i)
+		}
+	}
+
+	// Time each operator is busy until and the finish time of the tasks taken
+	op_end := make([]float64, num_operators)
+	finish := make(map[int]float64)
+	var placed []mannedTask
+	work := 0.0
+	for {
+		pick, pick_op, pick_start := -1, 0, 0.0
+		for pos, idx := range avail {
+			op, start := ms.placeTask(idx, op_end, finish)
+			if start+tc.tasks[idx].cost > tc.cycleTime+k_time_epsilon {
+				continue
+			}
+			if pick == -1 || ms.less(idx, avail[pick]) {
+				pick, pick_op, pick_start = pos, op, start
+			}
+		}
+		if pick == -1 {
+			break
+		}
+
+		idx := avail[pick]
+		avail = append(avail[:pick], avail[pick+1:]...)
+		placed = append(placed, mannedTask{idx, pick_op, pick_start})
+		finish[idx] = pick_start + tc.tasks[idx].cost
+		op_end[pick_op] = finish[idx]
+		work += tc.tasks[idx].cost
+		for _, post_idx := range ms.graph.postreqs[idx] {
+			local[post_idx]--
+			if local[post_idx] == 0 {
+				avail = append(avail, post_idx)
+			}
+		}
+	}
+
+	used := make(map[int]bool)
+	for _, task := range placed {
+		used[task.op] = true
+	}
+	return placed, work, len(used)
+}
+
+// Returns the operator where the task starts first at the open station and when
+// it starts. Of operators where it starts as early the one left idle the least
+// before it is used, then the first.
+func (ms *mannedSearch) placeTask(idx int, op_end []float64, finish map[int]float64) (int, float64) {
+	ready := 0.0
+	for _, prereq_idx := range ms.graph.prereqs[idx] {
+		if end, ok := finish[prereq_idx]; ok && end > ready {
+			ready = end
+		}
+	}
+
+	best_op, best_start := 0, math.Inf(1)
+	for op, end := range op_end {
+		start := math.Max(end, ready)
+		if start < best_start-k_time_epsilon ||
+			(start < best_start+k_time_epsilon && end > op_end[best_op]) {
+			best_op, best_start = op, start
+		}
+	}
+	return best_op, best_start
+}
+
+///////////////////////////////////
+// Schedule
+///////////////////////////////////
+
+// Returns a line for each operator of a solution with start times, on a
+// two-sided line or at multi-manned stations, like
+// "Station 1   Operator 2   |####====....|   3 0.00-12.00   5 12.00-20.00"
+// The bar spans the cycle time with the tasks in turn drawn with # and = and
+// idle time with dots. Empty for a solution without start times.
+func PrettyScheduleStr(sol *PartialSolution) string {
+	tc := sol.getTaskContainer()
+	if sol.starts == nil || tc.cycleTime <= 0.0 {
+		return ""
+	}
+
+	by_operator := make(map[operatorKey][]scheduledTask)
+	var keys []operatorKey
+	for _, asg := range sol.assignments {
+		key := operatorKey{asg.j, sol.sides[asg.i], sol.operators[asg.i]}
+		if _, ok := by_operator[key]; !ok {
+			keys = append(keys, key)
+		}
+		start := sol.starts[asg.i]
+		end := start + tc.tasks[tc.taskMapping[asg.i]].cost
+		by_operator[key] = append(by_operator[key], scheduledTask{start, end, asg.i})
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if keys[a].ws != keys[b].ws {
+			return keys[a].ws < keys[b].ws
+		}
+		if keys[a].side != keys[b].side {
+			return keys[a].side < keys[b].side
+		}
+		return keys[a].op < keys[b].op
+	})
+
+	str := ""
+	for _, key := range keys {
+		tasks := by_operator[key]
+		sort.Slice(tasks, func(a, b int) bool {
+			return tasks[a].start < tasks[b].start
+		})
+
+		name := "Operator " + strconv.Itoa(key.op)
+		if key.side == SideLeft {
+			name = "Left"
+		} else if key.side == SideRight {
+			name = "Right"
+		}
+
+		bar := []byte(strings.Repeat(".", k_schedule_width))
+		times := ""
+		for i, task := range tasks {
+			mark := byte('#')
+			if i%2 == 1 {
+				mark = '='
+			}
+			for pos, _ := range bar {
+				mid := (float64(pos) + 0.5) * tc.cycleTime / k_schedule_width
+				if mid >= task.start && mid < task.end {
+					bar[pos] = mark
+				}
+			}
+			times += "   " + strconv.Itoa(int(task.id)) + " " + strconv.FormatFloat(task.start, 'f', 2, 64) +
+				"-" + strconv.FormatFloat(task.end, 'f', 2, 64)
+		}
+
+		str += "Station " + strconv.Itoa(int(key.ws)) + "   " + name + "   |" + string(bar) + "|" + times + "\n"
+	}
+	return str
+}
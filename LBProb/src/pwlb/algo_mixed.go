@@ -0,0 +1,210 @@
+package pwlb
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A product variant built on a mixed-model line
+type ProductModel struct {
+	name  string
+	share float64              // share of the demand, shares are relative to their sum
+	times map[TaskId_t]float64 // task times of the model, tasks not listed take no time
+}
+
+func (pm *ProductModel) GetName() string {
+	return pm.name
+}
+
+func (pm *ProductModel) GetShare() float64 {
+	return pm.share
+}
+
+// Returns the time the model needs for a task, zero if the model doesn't need it
+func (pm *ProductModel) GetTaskTime(id TaskId_t) float64 {
+	return pm.times[id]
+}
+
+// Returns a model as a spec line like "model,A,0.6,1:12.0 2:8.5" with the task
+// times in task id order
+func (pm *ProductModel) ToStr() string {
+	var ids []TaskId_t
+	for id, _ := range pm.times {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(a, b int) bool {
+		return ids[a] < ids[b]
+	})
+
+	var pairs []string
+	for _, id := range ids {
+		pairs = append(pairs, strconv.Itoa(int(id))+":"+costToStr(pm.times[id]))
+	}
+	return "model," + pm.name + "," + strconv.FormatFloat(pm.share, 'f', -1, 64) + "," +
+		strings.Join(pairs, " ")
+}
+
+func (tc *TaskContainer) GetModels() []ProductModel {
+	return tc.models
+}
+
+// Parses a line like "model,A,0.6,1:12.0 2:8.5" naming a model, its share of the
+// demand and the time it needs for each task
+func (tc *TaskContainer) parseModel(fields []string) error {
+	if len(fields) != 4 {
+		return errors.New("Improper model format")
+	}
+
+	name := strings.TrimSpace(fields[1])
+	share, err := strconv.ParseFloat(strings.TrimSpace(fields[2]), 64)
+	if err != nil || share <= 0.0 {
+		return errors.New("Model share must be a positive number")
+	}
+	for i, _ := range tc.models {
+		if tc.models[i].name == name {
+			return errors.New("Model " + name + " defined twice")
+		}
+	}
+
+	model := ProductModel{name: name, share: share, times: make(map[TaskId_t]float64)}
+	for _, pair := range strings.Fields(fields[3]) {
+		id_time := strings.Split(pair, ":")
+		if len(id_time) != 2 {
+			return errors.New("Improper model task time " + pair)
+		}
+		id, id_err := strconv.Atoi(id_time[0])
+		task_time, time_err := strconv.ParseFloat(id_time[1], 64)
+		if id_err != nil || time_err != nil || task_time < 0.0 {
+			return errors.New("Improper model task time " + pair)
+		}
+		model.times[TaskId_t(id)] = task_time
+	}
+
+	tc.models = append(tc.models, model)
+	return nil
+}
+
+// Sets the time of every task to its average over the models weighted by their
+// shares, so the solvers balance the combined precedence graph on average time.
+// The times given in the task lines are replaced.
+func (tc *TaskContainer) averageModelTimes() {
+	for j, _ := range tc.models {
+		for id, _ := range tc.models[j].times {
+			if _, ok := tc.taskMapping[id]; !ok {
+				log.Fatal("Model " + tc.models[j].name + " has a time for unknown task " +
+					strconv.Itoa(int(id)))
+			}
+		}
+	}
+
+	share_sum := 0.0
+	for i, _ := range tc.models {
+		share_sum += tc.models[i].share
+	}
+
+	for i, _ := range tc.tasks {
+		avg := 0.0
+		for j, _ := range tc.models {
+			avg += tc.models[j].share * tc.models[j].times[tc.tasks[i].id]
+		}
+		tc.tasks[i].cost = avg / share_sum
+	}
+}
+
+// The time one model needs at one station
+type ModelStationLoad struct {
+	model   string
+	station WorkstationId_t
+	load    float64
+}
+
+func (msl *ModelStationLoad) GetModel() string {
+	return msl.model
+}
+
+func (msl *ModelStationLoad) GetStation() WorkstationId_t {
+	return msl.station
+}
+
+func (msl *ModelStationLoad) GetLoad() float64 {
+	return msl.load
+}
+
+// Returns the load of every model at every station of the solution, by station
+// then model in the order they were defined
+func (sol *PartialSolution) GetModelLoads() []ModelStationLoad {
+	tc := sol.getTaskContainer()
+	if len(tc.models) == 0 || len(sol.assignments) == 0 {
+		return nil
+	}
+
+	ws_start_id := sol.assignments[0].j
+	ws_end_id := ws_start_id
+	for _, asg := range sol.assignments {
+		if asg.j < ws_start_id {
+			ws_start_id = asg.j
+		}
+		if asg.j >= ws_end_id {
+			ws_end_id = asg.j + 1
+		}
+	}
+
+	num_models := len(tc.models)
+	loads := make([]ModelStationLoad, int(ws_end_id-ws_start_id)*num_models)
+	for i, _ := range loads {
+		loads[i].model = tc.models[i%num_models].name
+		loads[i].station = ws_start_id + WorkstationId_t(i/num_models)
+	}
+	for _, asg := range sol.assignments {
+		row := int(asg.j-ws_start_id) * num_models
+		for j, _ := range tc.models {
+			loads[row+j].load += tc.models[j].times[asg.i]
+		}
+	}
+	return loads
+}
+
+// Returns the model loads that exceed the cycle time
+func (sol *PartialSolution) GetModelOverruns() []ModelStationLoad {
+	cycle_time := sol.getTaskContainer().cycleTime
+
+	var overruns []ModelStationLoad
+	for _, msl := range sol.GetModelLoads() {
+		if msl.load > cycle_time+k_time_epsilon {
+			overruns = append(overruns, msl)
+		}
+	}
+	return overruns
+}
+
+// Returns the load of each model at each station, one station per line like
+// "Station 1:      A 45.00   B 52.00 over", where over marks a load exceeding
+// the cycle time
+func PrettyModelLoadsStr(sol *PartialSolution) string {
+	cycle_time := sol.getTaskContainer().cycleTime
+
+	str := ""
+	loads := sol.GetModelLoads()
+	for i, msl := range loads {
+		if i == 0 || loads[i-1].station != msl.station {
+			if i != 0 {
+				str += "\n"
+			}
+			str += "Station " + strconv.Itoa(int(msl.station)) + ":      "
+		} else {
+			str += "   "
+		}
+
+		str += msl.model + " " + strconv.FormatFloat(msl.load, 'f', 2, 64)
+		if msl.load > cycle_time+k_time_epsilon {
+			str += " over"
+		}
+	}
+	if str != "" {
+		str += "\n"
+	}
+	return str
+}
@@ -0,0 +1,68 @@
+package pwlb
+
+import (
+	"math"
+	"strconv"
+)
+
+// Returns the number of parallel copies of a station, each working on every
+// other product so the station has that many times the cycle time
+func (sol *PartialSolution) GetReplicas(wsid WorkstationId_t) int {
+	if replicas, ok := sol.replicas[wsid]; ok {
+		return replicas
+	}
+	return 1
+}
+
+// Returns the cost of the line counting each station as one and each copy of a
+// station beyond the first as the given replica cost
+func (sol *PartialSolution) GetLineCost(replica_cost float64) float64 {
+	line_cost := 0.0
+	counted := make(map[WorkstationId_t]bool)
+	for _, asg := range sol.assignments {
+		if !counted[asg.j] {
+			counted[asg.j] = true
+			line_cost += 1.0 + replica_cost*float64(sol.GetReplicas(asg.j)-1)
+		}
+	}
+	return line_cost
+}
+
+func (sol *PartialSolution) GetLineCostStr(replica_cost float64) string {
+	return strconv.FormatFloat(sol.GetLineCost(replica_cost), 'f', 1, 64)
+}
+
+// Returns the most copies of a station the options allow, one when stations
+// can't be replicated
+func (opts *SolveOptions) getMaxReplicas() int {
+	if opts == nil || opts.MaxReplicas < 1 {
+		return 1
+	}
+	return opts.MaxReplicas
+}
+
+// Returns the copies of a station needed to fit a task
+func (tc *TaskContainer) replicasNeeded(task_time float64) int {
+	return int(math.Max(1.0, float64(tc.stationsNeeded(task_time))))
+}
+
+// Returns why a task fits no station with up to the given copies, longer than
+// their cycle time or, when its mean time fits, too likely to overrun it at the
+// service level of the options
+func (tc *TaskContainer) unfitTaskStr(idx int, max_replicas int, opts *SolveOptions) string {
+	_task := &tc.tasks[idx]
+	if _task.cost > tc.cycleTime*float64(max_replicas)+k_time_epsilon {
+		if max_replicas > 1 {
+			return "Task " + _task.ToStr() + " needs " + strconv.Itoa(tc.replicasNeeded(_task.cost)) +
+				" copies of a station, more than the " + strconv.Itoa(max_replicas) + " allowed"
+		}
+		return "Task " + _task.ToStr() + " exceeds the cycle time"
+	}
+
+	str := "Task " + _task.ToStr() + " can't meet the service level " +
+		strconv.FormatFloat(opts.ServiceLevel, 'f', -1, 64) + " within the cycle time"
+	if max_replicas > 1 {
+		str += " of " + strconv.Itoa(max_replicas) + " station copies"
+	}
+	return str
+}
@@ -0,0 +1,310 @@
+package pwlb
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Workstations from first to last a task is pinned to, like a task using a press
+// that can't be moved
+type StationRange struct {
+	first, last WorkstationId_t
+}
+
+func (sr *StationRange) GetFirst() WorkstationId_t {
+	return sr.first
+}
+
+func (sr *StationRange) GetLast() WorkstationId_t {
+	return sr.last
+}
+
+func (sr *StationRange) Contains(wsid WorkstationId_t) bool {
+	return sr.first <= wsid && wsid <= sr.last
+}
+
+// Returns a range as a string like "3" or "2-4"
+func (sr *StationRange) ToStr() string {
+	if sr.first == sr.last {
+		return strconv.Itoa(int(sr.first))
+	}
+	return strconv.Itoa(int(sr.first)) + "-" + strconv.Itoa(int(sr.last))
+}
+
+// Parses a range like "3" or "2-4"
+func ParseStationRange(str string) (StationRange, error) {
+	bounds := strings.SplitN(str, "-", 2)
+	first, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return StationRange{}, errors.New("Improper station range " + str)
+	}
+	last := first
+	if len(bounds) == 2 {
+		if last, err = strconv.Atoi(bounds[1]); err != nil {
+			return StationRange{}, errors.New("Improper station range " + str)
+		}
+	}
+	if last < first {
+		return StationRange{}, errors.New("Station range " + str + " ends before it starts")
+	}
+	return StationRange{WorkstationId_t(first), WorkstationId_t(last)}, nil
+}
+
+// Returns the stations the task is pinned to, nil if it may go to any station
+func (t *Task) GetPin() *StationRange {
+	return t.pin
+}
+
+// Pins a task to the workstations from first to last, both included
+func (tc *TaskContainer) PinTask(id TaskId_t, first, last WorkstationId_t) error {
+	task_idx, ok := tc.taskMapping[id]
+	if !ok {
+		return errors.New("Unknown task " + strconv.Itoa(int(id)) + " to pin")
+	}
+	if last < first {
+		return errors.New("Station range of task " + strconv.Itoa(int(id)) + " ends before it starts")
+	}
+	tc.tasks[task_idx].pin = &StationRange{first, last}
+	return nil
+}
+
+// Checks that the pins of the tasks can all be met, see getPinWindows. The
+// options may be nil.
+func (tc *TaskContainer) CheckPins(opts *SolveOptions) error {
+	_, err := tc.getPinWindows(opts)
+	return err
+}
+
+///////////////////////////////////
+// Pin windows
+///////////////////////////////////
+
+// Earliest and latest station of each task by container index as the pins allow,
+// with the index of the pinned task each comes from, or -1 where none does
+type pinWindows struct {
+	release     []WorkstationId_t
+	deadline    []WorkstationId_t
+	releaseSrc  []int
+	deadlineSrc []int
+}
+
+// Returns the stations each task may go to given the pins, or nil when no task is
+// pinned. A task can't go before a station its predecessors are pinned after or
+// after one its followers are pinned before. On a U-line a task may come after
+// its followers so only its own pin counts. Fails naming the pins and the
+// precedence between them when a task is left no station, when too few tasks may
+// go before a pinned task to fill the stations before it, and when tasks left
+// only the same stations don't fit them or are zoned apart.
+func (tc *TaskContainer) getPinWindows(opts *SolveOptions) (*pinWindows, error) {
+	pinned := false
+	for i, _ := range tc.tasks {
+		pinned = pinned || tc.tasks[i].pin != nil
+	}
+	if !pinned {
+		return nil, nil
+	}
+
+	num_tasks := len(tc.tasks)
+	ws_start_id := WorkstationId_t(tc.tasks[0].id)
+	pw := &pinWindows{
+		release:     make([]WorkstationId_t, num_tasks),
+		deadline:    make([]WorkstationId_t, num_tasks),
+		releaseSrc:  make([]int, num_tasks),
+		deadlineSrc: make([]int, num_tasks),
+	}
+	for i, _ := range tc.tasks {
+		pw.release[i], pw.deadline[i] = ws_start_id, math.MaxInt32
+		pw.releaseSrc[i], pw.deadlineSrc[i] = -1, -1
+		if pin := tc.tasks[i].pin; pin != nil {
+			if pin.first < ws_start_id {
+				return nil, errors.New("Task " + strconv.Itoa(int(tc.tasks[i].id)) + " pinned to station " +
+					pin.ToStr() + " before the first station " + strconv.Itoa(int(ws_start_id)))
+			}
+			pw.release[i], pw.deadline[i] = pin.first, pin.last
+			pw.releaseSrc[i], pw.deadlineSrc[i] = i, i
+		}
+	}
+
+	graph := tc.GetGraph()
+	if tc.layout != LayoutU {
+		for _, idx := range graph.topoOrder {
+			for _, post_idx := range graph.postreqs[idx] {
+				if pw.release[idx] > pw.release[post_idx] {
+					pw.release[post_idx], pw.releaseSrc[post_idx] = pw.release[idx], pw.releaseSrc[idx]
+				}
+			}
+		}
+		for i := num_tasks - 1; i >= 0; i-- {
+			idx := graph.topoOrder[i]
+			for _, prereq_idx := range graph.prereqs[idx] {
+				if pw.deadline[idx] < pw.deadline[prereq_idx] {
+					pw.deadline[prereq_idx], pw.deadlineSrc[prereq_idx] = pw.deadline[idx], pw.deadlineSrc[idx]
+				}
+			}
+		}
+	}
+
+	for _, idx := range graph.topoOrder {
+		if pw.release[idx] > pw.deadline[idx] {
+			err_str := "Pins can't be met, task " + tc.pinStr(pw.releaseSrc[idx]) +
+				" comes before task " + tc.pinStr(pw.deadlineSrc[idx])
+			if idx != pw.releaseSrc[idx] && idx != pw.deadlineSrc[idx] {
+				err_str += " through task " + strconv.Itoa(int(tc.tasks[idx].id))
+			}
+			return nil, errors.New(err_str)
+		}
+	}
+
+	// Every station before a pinned task needs a task of its own, so the tasks that
+	// may go before it have to be at least as many as those stations
+	releases := make(map[WorkstationId_t]int)
+	for i, _ := range tc.tasks {
+		if _, ok := releases[pw.release[i]]; !ok {
+			releases[pw.release[i]] = i
+		}
+	}
+	var firsts []WorkstationId_t
+	for wsid, _ := range releases {
+		firsts = append(firsts, wsid)
+	}
+	sort.Slice(firsts, func(a, b int) bool {
+		return firsts[a] < firsts[b]
+	})
+	for _, wsid := range firsts {
+		num_before := 0
+		for i, _ := range tc.tasks {
+			if pw.release[i] < wsid {
+				num_before++
+			}
+		}
+		if num_stations := int(wsid - ws_start_id); num_before < num_stations {
+			idx := releases[wsid]
+			return nil, errors.New("Pins can't be met, task " + tc.pinStr(pw.releaseSrc[idx]) + " needs a task at each of the " +
+				strconv.Itoa(num_stations) + " stations before it but only " + strconv.Itoa(num_before) + " can go there")
+		}
+	}
+
+	// Tasks left the same stations have to share them
+	capacity := tc.cycleTime * float64(opts.getMaxReplicas())
+	if tc.layout == LayoutTwoSided {
+		capacity = 2.0 * tc.cycleTime
+	}
+	var lasts []WorkstationId_t
+	seen := make(map[WorkstationId_t]bool)
+	for i, _ := range tc.tasks {
+		if pw.deadline[i] != math.MaxInt32 && !seen[pw.deadline[i]] {
+			seen[pw.deadline[i]] = true
+			lasts = append(lasts, pw.deadline[i])
+		}
+	}
+	sort.Slice(lasts, func(a, b int) bool {
+		return lasts[a] < lasts[b]
+	})
+	for _, first := range firsts {
+		for _, last := range lasts {
+			if last < first {
+				continue
+			}
+			var idxs []int
+			load := 0.0
+			for i, _ := range tc.tasks {
+				if first <= pw.release[i] && pw.deadline[i] <= last {
+					idxs = append(idxs, i)
+					load += tc.tasks[i].cost
+				}
+			}
+			if load <= capacity*float64(last-first+1)+k_time_epsilon {
+				continue
+			}
+			if first == last {
+				return nil, errors.New("Pins can't be met, tasks " + tc.idxsToStr(idxs) +
+					" have to be at station " + strconv.Itoa(int(first)) + " and exceed the cycle time")
+			}
+			stations := StationRange{first, last}
+			return nil, errors.New("Pins can't be met, tasks " + tc.idxsToStr(idxs) +
+				" have to be at stations " + stations.ToStr() + " and exceed their cycle times")
+		}
+	}
+	for i, _ := range tc.zones {
+		if tc.zones[i].kind != ZoneApart {
+			continue
+		}
+		at := make(map[WorkstationId_t]TaskId_t)
+		for _, id := range tc.zones[i].tasks {
+			idx := tc.taskMapping[id]
+			if pw.release[idx] != pw.deadline[idx] {
+				continue
+			}
+			if other_id, ok := at[pw.release[idx]]; ok {
+				return nil, errors.New("Pins can't be met, tasks " + strconv.Itoa(int(other_id)) + " and " +
+					strconv.Itoa(int(id)) + " zoned apart have to be at station " + strconv.Itoa(int(pw.release[idx])))
+			}
+			at[pw.release[idx]] = id
+		}
+	}
+
+	return pw, nil
+}
+
+// Returns a pinned task as a string like "4 pinned to station 2-3"
+func (tc *TaskContainer) pinStr(idx int) string {
+	return strconv.Itoa(int(tc.tasks[idx].id)) + " pinned to station " + tc.tasks[idx].pin.ToStr()
+}
+
+// Returns true if the task may go to the station, always when nothing is pinned
+func (pw *pinWindows) allows(idx int, wsid WorkstationId_t) bool {
+	return pw == nil || (pw.release[idx] <= wsid && wsid <= pw.deadline[idx])
+}
+
+// Returns a priority rule that puts the tasks that can't go after the open station
+// first and breaks ties with the given rule. The heap has to be reordered each
+// time a station is opened.
+func (pw *pinWindows) dueFirst(less func(lhs, rhs int) bool, cur_ws_id *WorkstationId_t) func(lhs, rhs int) bool {
+	return func(lhs, rhs int) bool {
+		lhs_due, rhs_due := pw.deadline[lhs] <= *cur_ws_id, pw.deadline[rhs] <= *cur_ws_id
+		if lhs_due != rhs_due {
+			return lhs_due
+		}
+		return less(lhs, rhs)
+	}
+}
+
+// Stops the solve when the open station is closed while a task that can't go
+// after it is still available, the heuristic then ran out of room to meet a pin
+func (pw *pinWindows) checkClosed(tc *TaskContainer, avail *taskHeap, wsid WorkstationId_t) {
+	if pw == nil || avail.Len() == 0 || pw.deadline[avail.idxs[0]] > wsid {
+		return
+	}
+	idx := avail.idxs[0]
+	panic("The solver can't meet the pin of task " + tc.pinStr(pw.deadlineSrc[idx]) + ", station " +
+		strconv.Itoa(int(wsid)) + " is full before task " + strconv.Itoa(int(tc.tasks[idx].id)))
+}
+
+// Stops the solve when the open station is empty and the first available task
+// fits it but may not go to it, so nothing can
+func (pw *pinWindows) checkEmpty(tc *TaskContainer, avail *taskHeap, wsid WorkstationId_t) {
+	idx := avail.idxs[0]
+	if pw.allows(idx, wsid) {
+		return
+	}
+	if pw.release[idx] > wsid {
+		panic("The solver can't meet the pin of task " + tc.pinStr(pw.releaseSrc[idx]) +
+			", no task is left for station " + strconv.Itoa(int(wsid)))
+	}
+	panic("The solver can't meet the pin of task " + tc.pinStr(pw.deadlineSrc[idx]) +
+		", task " + strconv.Itoa(int(tc.tasks[idx].id)) + " comes after station " + strconv.Itoa(int(pw.deadline[idx])))
+}
+
+// Returns an error for the first task at a station its pin doesn't allow
+func (tc *TaskContainer) checkPinned(station_of map[TaskId_t]WorkstationId_t) error {
+	for i, _ := range tc.tasks {
+		pin := tc.tasks[i].pin
+		if wsid, ok := station_of[tc.tasks[i].id]; ok && pin != nil && !pin.Contains(wsid) {
+			return errors.New("Task " + tc.pinStr(i) + " is at workstation " + strconv.Itoa(int(wsid)))
+		}
+	}
+	return nil
+}
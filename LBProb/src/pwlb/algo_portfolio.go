@@ -0,0 +1,165 @@
+package pwlb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Returns a solver that runs the named solvers at once on goroutines of their own.
+// They share the fewest stations found and the best lower bound, so branch and
+// bound cuts branches with the solutions of the heuristics. All of them are
+// stopped as soon as one solution is known to be optimal or the context ends. The
+// result is the solution with the fewest stations, from the solver listed first
+// on a tie.
+//
+// An observer of the portfolio is called from one goroutine at a time. It is told
+// of every solution better than those before and every higher bound, and gets the
+// iterations of all solvers added up. Solvers that can't solve the problem with
+// the options, see solverSupports, are left out.
+func NewPortfolioSolver(names []string) (SolverFunc, error) {
+	if len(names) == 0 {
+		return nil, errors.New("A portfolio needs at least one solver")
+	}
+
+	for _, name := range names {
+		if _, ok := solvers[name]; !ok {
+			return nil, errors.New("Unknown solver " + name + " in portfolio")
+		}
+	}
+
+	var portfolio_solver SolverFunc
+	portfolio_solver = func(ctx context.Context, tc *TaskContainer, opts *SolveOptions) *SolveResult {
+		// Tasks zoned together are merged once for all solvers
+		if tc.hasTogetherZones() {
+			return solveZoned(ctx, tc, opts, portfolio_solver)
+		}
+
+		var portfolio []SolverFunc
+		for _, name := range names {
+			if solverSupports(name, tc, opts) {
+				portfolio = append(portfolio, solvers[name])
+			}
+		}
+		return solvePortfolio(ctx, tc, opts, portfolio)
+	}
+	return portfolio_solver, nil
+}
+
+// Passes the events of all solvers in a portfolio to its observer one at a time
+type portfolioObserver struct {
+	mutex    sync.Mutex
+	progress *solveProgress
+	best     int
+}
+
+func (po *portfolioObserver) observe(ev *SolveEvent) {
+	po.mutex.Lock()
+	defer po.mutex.Unlock()
+
+	switch ev.kind {
+	case EventIncumbent:
+		if stations := ev.incumbent.GetMeasuredMin(); po.best == 0 || stations < po.best {
+			po.best = stations
+			po.progress.state.incumbent = ev.incumbent
+			po.progress.notify(EventIncumbent)
+		}
+	case EventBound:
+		if ev.lowerBound > po.progress.state.lowerBound {
+			po.progress.state.lowerBound = ev.lowerBound
+			po.progress.notify(EventBound)
+		}
+	case EventIteration:
+		po.progress.iteration()
+	}
+}
+
+func solvePortfolio(ctx context.Context, tc *TaskContainer, opts *SolveOptions,
+	portfolio []SolverFunc) *SolveResult {
+	if len(tc.tasks) == 0 {
+		return newCompletedResult(&PartialSolution{tc: tc})
+	}
+
+	// The lazy parts of the graph are built here, before the solvers read it from
+	// several goroutines
+	graph := tc.GetGraph()
+	graph.buildClosure()
+	graph.buildLongestPaths()
+
+	shared := &sharedBounds{}
+	if opts != nil && opts.shared != nil {
+		shared = opts.shared
+	}
+	solver_opts := &SolveOptions{shared: shared}
+	if opts != nil {
+		solver_opts.MaxReplicas = opts.MaxReplicas
+		solver_opts.ReplicaCost = opts.ReplicaCost
+		solver_opts.ServiceLevel = opts.ServiceLevel
+	}
+	if opts != nil && opts.Observer != nil {
+		po := &portfolioObserver{progress: &solveProgress{observer: opts.Observer,
+			shared: shared, start: time.Now()}}
+		solver_opts.Observer = po.observe
+	}
+
+	solve_ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// A panic ends the whole portfolio the same as it would end a single solver
+	results := make([]*SolveResult, len(portfolio))
+	panics := make([]interface{}, len(portfolio))
+	var wg sync.WaitGroup
+	for i, solver := range portfolio {
+		wg.Add(1)
+		go func(i int, solver SolverFunc) {
+			defer wg.Done()
+			defer func() {
+				if err := recover(); err != nil {
+					panics[i] = err
+					cancel()
+				}
+			}()
+
+			results[i] = solver(solve_ctx, tc, solver_opts)
+			if _, _, optimal := shared.get(); optimal {
+				cancel()
+			}
+		}(i, solver)
+	}
+	wg.Wait()
+
+	for _, err := range panics {
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// Pick the complete solution with the fewest stations, an interrupted solve
+	// only counts if none completed
+	var best *SolveResult
+	for _, result := range results {
+		if len(result.sol.assignments) != len(tc.tasks) {
+			continue
+		}
+		if best == nil || result.sol.GetMeasuredMin() < best.sol.GetMeasuredMin() {
+			best = result
+		}
+	}
+	if best == nil {
+		for _, result := range results {
+			if best == nil || len(result.sol.assignments) > len(best.sol.assignments) {
+				best = result
+			}
+		}
+		return &SolveResult{best.sol, SolveInterrupted}
+	}
+
+	if _, _, optimal := shared.get(); optimal {
+		return &SolveResult{best.sol, SolveOptimal}
+	}
+	if ctx.Err() != nil {
+		return &SolveResult{best.sol, SolveInterrupted}
+	}
+	return newCompletedResult(best.sol)
+}
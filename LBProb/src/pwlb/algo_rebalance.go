@@ -0,0 +1,354 @@
+package pwlb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// What happened to a task between an old solution and a rebalanced one
+type MoveKind int
+
+const (
+	TaskMoved   MoveKind = iota // at another station than before
+	TaskAdded                   // new in the spec, it has no old station
+	TaskRemoved                 // gone from the spec, it has no new station
+)
+
+// A change of a task between an old solution and a rebalanced one
+type TaskMove struct {
+	kind     MoveKind
+	task     TaskId_t
+	from, to WorkstationId_t
+}
+
+func (tm *TaskMove) GetKind() MoveKind {
+	return tm.kind
+}
+
+func (tm *TaskMove) GetTask() TaskId_t {
+	return tm.task
+}
+
+// Returns the station the task had before, not set for an added task
+func (tm *TaskMove) GetFrom() WorkstationId_t {
+	return tm.from
+}
+
+// Returns the station the task has now, not set for a removed task
+func (tm *TaskMove) GetTo() WorkstationId_t {
+	return tm.to
+}
+
+// Returns a change like "Task 4:      Moved 2 -> 3"
+func (tm *TaskMove) ToStr() string {
+	str := "Task " + strconv.Itoa(int(tm.task)) + ":      "
+	switch tm.kind {
+	case TaskMoved:
+		str += "Moved " + strconv.Itoa(int(tm.from)) + " -> " + strconv.Itoa(int(tm.to))
+	case TaskAdded:
+		str += "Added at " + strconv.Itoa(int(tm.to))
+	case TaskRemoved:
+		str += "Removed from " + strconv.Itoa(int(tm.from))
+	}
+	return str
+}
+
+// A solution of a changed spec found from the solution of the spec before
+type RebalanceResult struct {
+	sol   *PartialSolution
+	moves []TaskMove // by task id
+}
+
+func (rr *RebalanceResult) GetSolution() *PartialSolution {
+	return rr.sol
+}
+
+func (rr *RebalanceResult) GetMoves() []TaskMove {
+	return rr.moves
+}
+
+// Returns the number of changes of the given kind
+func (rr *RebalanceResult) Count(kind MoveKind) int {
+	num := 0
+	for i, _ := range rr.moves {
+		if rr.moves[i].kind == kind {
+			num++
+		}
+	}
+	return num
+}
+
+// Finds a solution of the tasks in new_tc that moves as few of the tasks of the
+// old solution as it can, then uses the fewest stations, then has the lowest
+// smoothness index. Stations are counted from the first task in both, so a task
+// keeps its station when it is the same number of stations from the start.
+//
+// The SST and RPW solvers are run with every task kept out of the stations
+// before its old one and taken first at its old one, new tasks and tasks that
+// couldn't stay filling the room left. A station that would be left empty takes
+// a task from a later one. Both are also run from scratch, which helps when the
+// old stations can't be kept at all. Each of the four solutions then has its
+// moved tasks put back at their old stations one at a time while it stays valid
+// without more stations, and the best is kept. Like the solvers it is a
+// heuristic, fewer moves may be possible.
+//
+// Fails if the old solution isn't valid for the old tasks, the new tasks are on a
+// two-sided line, whose sides and start times the old stations don't keep, or no
+// solver solves the new tasks. The options may be nil.
+func Rebalance(ctx context.Context, old_sol *PartialSolution, new_tc *TaskContainer,
+	opts *SolveOptions) (*RebalanceResult, error) {
+	old_tc := old_sol.getTaskContainer()
+	if err := old_sol.Validate(); err != nil {
+		return nil, errors.New("Old solution is not valid, " + err.Error())
+	}
+	if new_tc.layout == LayoutTwoSided {
+		return nil, errors.New("Rebalance doesn't solve two-sided lines")
+	}
+	if len(new_tc.tasks) == 0 {
+		return newRebalanceResult(old_sol, &PartialSolution{tc: new_tc}), nil
+	}
+
+	// Old stations in the numbering of the new solution
+	offset := WorkstationId_t(new_tc.tasks[0].id) - WorkstationId_t(old_tc.tasks[0].id)
+	homes := make(map[TaskId_t]WorkstationId_t)
+	for _, asg := range old_sol.assignments {
+		homes[asg.i] = asg.j + offset
+	}
+
+	sticky_opts := &SolveOptions{homes: homes}
+	if opts != nil {
+		sticky_opts.MaxReplicas = opts.MaxReplicas
+		sticky_opts.ReplicaCost = opts.ReplicaCost
+		sticky_opts.ServiceLevel = opts.ServiceLevel
+	}
+
+	var best *RebalanceResult
+	var first_err error
+	for _, solve_opts := range []*SolveOptions{sticky_opts, opts} {
+		for _, solver := range []SolverFunc{SolveSST, SolveRPW} {
+			result, err := solveRebalanceCandidate(ctx, solver, new_tc, solve_opts)
+			if err != nil {
+				if first_err == nil {
+					first_err = err
+				}
+				continue
+			}
+			candidate := newRebalanceResult(old_sol, moveHome(ctx, result.sol, homes))
+			if best == nil || candidate.isBetterThan(best) {
+				best = candidate
+			}
+		}
+	}
+
+	if best == nil {
+		return nil, first_err
+	}
+	return best, nil
+}
+
+// Runs one solver for Rebalance, failing where the solver would panic or stop
+func solveRebalanceCandidate(ctx context.Context, solver SolverFunc, tc *TaskContainer,
+	opts *SolveOptions) (result *SolveResult, err error) {
+	defer func() {
+		if msg := recover(); msg != nil {
+			result, err = nil, errors.New(fmt.Sprint(msg))
+		}
+	}()
+
+	result = solver(ctx, tc, opts)
+	if result.status == SolveInterrupted {
+		return nil, errors.New("Rebalance interrupted")
+	}
+	return result, nil
+}
+
+// Puts moved tasks back at their old stations one at a time, at the last place
+// among the tasks of the station that keeps the solution valid without adding
+// stations, until no more can go back
+func moveHome(ctx context.Context, sol *PartialSolution, homes map[TaskId_t]WorkstationId_t) *PartialSolution {
+	for moved := true; moved && ctx.Err() == nil; {
+		moved = false
+		for i, asg := range sol.assignments {
+			if home, ok := homes[asg.i]; ok && home != asg.j {
+				if home_sol := sol.moveTask(i, home); home_sol != nil {
+					sol, moved = home_sol, true
+					break
+				}
+			}
+		}
+	}
+	return sol
+}
+
+// Returns a copy of the solution with the task of the assignment at the given
+// index moved among the tasks of another station, nil when no place there keeps
+// the solution valid without adding stations
+func (sol *PartialSolution) moveTask(asg_idx int, wsid WorkstationId_t) *PartialSolution {
+	id := sol.assignments[asg_idx].i
+	var rest []TaskAssignment
+	rest = append(rest, sol.assignments[:asg_idx]...)
+	rest = append(rest, sol.assignments[asg_idx+1:]...)
+
+	first, last := -1, -1
+	for i, asg := range rest {
+		if asg.j == wsid {
+			if first == -1 {
+				first = i
+			}
+			last = i + 1
+		}
+	}
+	if first == -1 {
+		return nil
+	}
+
+	// The task goes after its prereqs at the station and before its followers
+	tc := sol.getTaskContainer()
+	graph := tc.GetGraph()
+	idx := tc.taskMapping[id]
+	at_station := make(map[int]int)
+	for i := first; i < last; i++ {
+		at_station[tc.taskMapping[rest[i].i]] = i
+	}
+	for _, prereq_idx := range graph.prereqs[idx] {
+		if i, ok := at_station[prereq_idx]; ok && i+1 > first {
+			first = i + 1
+		}
+	}
+	for _, post_idx := range graph.postreqs[idx] {
+		if i, ok := at_station[post_idx]; ok && i < last {
+			last = i
+		}
+	}
+	for pos := last; pos >= first; pos-- {
+		moved_sol := &PartialSolution{tc: sol.tc, back: sol.back, replicas: sol.replicas}
+		moved_sol.assignments = append(moved_sol.assignments, rest[:pos]...)
+		moved_sol.assignments = append(moved_sol.assignments, TaskAssignment{id, wsid})
+		moved_sol.assignments = append(moved_sol.assignments, rest[pos:]...)
+		if moved_sol.Validate() == nil && moved_sol.GetMeasuredMin() <= sol.GetMeasuredMin() {
+			return moved_sol
+		}
+	}
+	return nil
+}
+
+// Lists the changes of every task from the old solution to the new one
+func newRebalanceResult(old_sol, sol *PartialSolution) *RebalanceResult {
+	rr := &RebalanceResult{sol: sol}
+	old_tc, new_tc := old_sol.getTaskContainer(), sol.getTaskContainer()
+	offset := WorkstationId_t(0)
+	if len(old_tc.tasks) != 0 && len(new_tc.tasks) != 0 {
+		offset = WorkstationId_t(new_tc.tasks[0].id) - WorkstationId_t(old_tc.tasks[0].id)
+	}
+
+	old_station := make(map[TaskId_t]WorkstationId_t)
+	for _, asg := range old_sol.assignments {
+		old_station[asg.i] = asg.j
+	}
+	new_station := make(map[TaskId_t]WorkstationId_t)
+	for _, asg := range sol.assignments {
+		new_station[asg.i] = asg.j
+		from, ok := old_station[asg.i]
+		if !ok {
+			rr.moves = append(rr.moves, TaskMove{kind: TaskAdded, task: asg.i, to: asg.j})
+		} else if from+offset != asg.j {
+			rr.moves = append(rr.moves, TaskMove{kind: TaskMoved, task: asg.i, from: from, to: asg.j})
+		}
+	}
+	for _, asg := range old_sol.assignments {
+		if _, ok := new_station[asg.i]; !ok {
+			rr.moves = append(rr.moves, TaskMove{kind: TaskRemoved, task: asg.i, from: asg.j})
+		}
+	}
+
+	sort.Slice(rr.moves, func(a, b int) bool {
+		return rr.moves[a].task < rr.moves[b].task
+	})
+	return rr
+}
+
+// Returns true if the result moves fewer tasks, or as many on fewer stations, or
+// as many on as many stations with a lower smoothness index
+func (rr *RebalanceResult) isBetterThan(other *RebalanceResult) bool {
+	if moved, other_moved := rr.Count(TaskMoved), other.Count(TaskMoved); moved != other_moved {
+		return moved < other_moved
+	}
+	if stations, other_stations := rr.sol.GetMeasuredMin(), other.sol.GetMeasuredMin(); stations != other_stations {
+		return stations < other_stations
+	}
+	return rr.sol.GetSmoothnessIndex() < other.sol.GetSmoothnessIndex()-k_time_epsilon
+}
+
+// Returns a line for each change of a rebalance
+func PrettyRebalanceStr(rr *RebalanceResult) string {
+	var lines []string
+	for i, _ := range rr.moves {
+		lines = append(lines, rr.moves[i].ToStr()+"\n")
+	}
+	return strings.Join(lines, "")
+}
+
+///////////////////////////////////
+// Station homes
+///////////////////////////////////
+
+// The station each task had before a rebalance by container index, a task is
+// kept out of the stations before it unless a station would be left empty
+type stationHomes struct {
+	home      []WorkstationId_t
+	hasHome   []bool
+	unblocked bool // the open station may take tasks from later ones
+}
+
+// Returns the homes of the tasks given in the options, nil when not rebalancing
+func newStationHomes(tc *TaskContainer, opts *SolveOptions) *stationHomes {
+	if opts == nil || opts.homes == nil {
+		return nil
+	}
+	sh := &stationHomes{home: make([]WorkstationId_t, len(tc.tasks)), hasHome: make([]bool, len(tc.tasks))}
+	for i, _ := range tc.tasks {
+		sh.home[i], sh.hasHome[i] = opts.homes[tc.tasks[i].id]
+	}
+	return sh
+}
+
+// Returns true if the task may go to the station
+func (sh *stationHomes) allows(idx int, wsid WorkstationId_t) bool {
+	return sh == nil || sh.unblocked || !sh.hasHome[idx] || sh.home[idx] <= wsid
+}
+
+// Lets the open station take tasks from later ones, returns false if it already
+// could or nothing is being rebalanced
+func (sh *stationHomes) unblock() bool {
+	if sh == nil || sh.unblocked {
+		return false
+	}
+	sh.unblocked = true
+	return true
+}
+
+// Returns a priority rule that puts the tasks of the open station first, then new
+// tasks and tasks that couldn't stay at their stations, then tasks of later
+// stations, and breaks ties with the given rule. The heap has to be reordered
+// each time a station is opened.
+func (sh *stationHomes) homeFirst(less func(lhs, rhs int) bool, cur_ws_id *WorkstationId_t) func(lhs, rhs int) bool {
+	rank := func(idx int) int {
+		if !sh.hasHome[idx] || sh.home[idx] < *cur_ws_id {
+			return 1
+		}
+		if sh.home[idx] == *cur_ws_id {
+			return 0
+		}
+		return 2
+	}
+	return func(lhs, rhs int) bool {
+		if lhs_rank, rhs_rank := rank(lhs), rank(rhs); lhs_rank != rhs_rank {
+			return lhs_rank < rhs_rank
+		}
+		return less(lhs, rhs)
+	}
+}
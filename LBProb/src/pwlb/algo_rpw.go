@@ -0,0 +1,47 @@
+package pwlb
+
+import "context"
+
+// Assigns the tasks in the container to stations with the ranked positional weight
+// heuristic. Stations are filled one at a time like SolveSST but the available
+// task with the largest positional weight, its time plus the time of all its
+// followers, is taken first among those that fit. Ties go to the task listed
+// first. The global containers are not used.
+//
+// On a U-line a task can also be done once its followers are, so its weight is
+// taken over whichever of its followers or predecessors take longer.
+func SolveRPW(ctx context.Context, tc *TaskContainer, opts *SolveOptions) *SolveResult {
+	if tc.hasTogetherZones() {
+		return solveZoned(ctx, tc, opts, SolveRPW)
+	}
+
+	return solveStationOriented(ctx, tc, opts, rpwLess(tc), false)
+}
+
+// Returns the RPW priority rule, larger positional weights first
+func rpwLess(tc *TaskContainer) func(lhs, rhs int) bool {
+	weights := rpwWeights(tc)
+	return func(lhs, rhs int) bool {
+		if weights[lhs] != weights[rhs] {
+			return weights[lhs] > weights[rhs]
+		}
+		return lhs < rhs
+	}
+}
+
+// Returns the positional weight of every task by container index
+func rpwWeights(tc *TaskContainer) []float64 {
+	if len(tc.tasks) == 0 {
+		return nil
+	}
+	graph := tc.GetGraph()
+	graph.buildClosure()
+	weights := make([]float64, len(tc.tasks))
+	for i, _ := range tc.tasks {
+		weights[i] = tc.tasks[i].cost + graph.followersTime[i]
+		if tc.layout == LayoutU && graph.predecessorsTime[i] > graph.followersTime[i] {
+			weights[i] = tc.tasks[i].cost + graph.predecessorsTime[i]
+		}
+	}
+	return weights
+}
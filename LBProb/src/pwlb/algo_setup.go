@@ -0,0 +1,220 @@
+package pwlb
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+)
+
+// A pair of tasks done one right after the other at a station
+type setupKey struct {
+	from, to TaskId_t
+}
+
+// Time a station needs to change over from one task to the next, like a tool
+// change between two tasks
+type SetupTime struct {
+	from, to TaskId_t
+	time     float64
+}
+
+func (st *SetupTime) GetFrom() TaskId_t {
+	return st.from
+}
+
+func (st *SetupTime) GetTo() TaskId_t {
+	return st.to
+}
+
+func (st *SetupTime) GetTime() float64 {
+	return st.time
+}
+
+// Returns a setup time as a spec line like "setup,1,2,3.5"
+func (st *SetupTime) ToStr() string {
+	return "setup," + strconv.Itoa(int(st.from)) + "," + strconv.Itoa(int(st.to)) + "," + costToStr(st.time)
+}
+
+// Returns true if some pair of tasks has a setup time
+func (tc *TaskContainer) HasSetups() bool {
+	return len(tc.setups) != 0
+}
+
+// Returns every setup time of the spec by the task it is from, then the task it
+// is to
+func (tc *TaskContainer) GetSetups() []SetupTime {
+	var setups []SetupTime
+	for key, time := range tc.setups {
+		setups = append(setups, SetupTime{key.from, key.to, time})
+	}
+	sort.Slice(setups, func(a, b int) bool {
+		if setups[a].from != setups[b].from {
+			return setups[a].from < setups[b].from
+		}
+		return setups[a].to < setups[b].to
+	})
+	return setups
+}
+
+// Returns the time a station needs between the two tasks when the one comes
+// right after the other, zero for a pair without a setup time
+func (tc *TaskContainer) GetSetupTime(from, to TaskId_t) float64 {
+	return tc.setups[setupKey{from, to}]
+}
+
+// Parses a line like "setup,1,2,3.5", the time a station needs between task 1
+// and task 2 when task 2 comes right after it
+func (tc *TaskContainer) parseSetup(fields []string) error {
+	if len(fields) != 4 {
+		return errors.New("Improper setup format")
+	}
+	from, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return errors.New("Improper task id " + fields[1] + " in setup")
+	}
+	to, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return errors.New("Improper task id " + fields[2] + " in setup")
+	}
+	if from == to {
+		return errors.New("A setup needs two different tasks")
+	}
+	time, err := strconv.ParseFloat(fields[3], 64)
+	if err != nil || time < 0.0 {
+		return errors.New("Setup time must be a number no less than zero")
+	}
+
+	if tc.setups == nil {
+		tc.setups = make(map[setupKey]float64)
+	}
+	tc.setups[setupKey{TaskId_t(from), TaskId_t(to)}] = time
+	return nil
+}
+
+// Stops when a setup time names a task that isn't in the spec
+func (tc *TaskContainer) checkSetupTasks() {
+	for key, _ := range tc.setups {
+		for _, id := range []TaskId_t{key.from, key.to} {
+			if _, ok := tc.taskMapping[id]; !ok {
+				log.Fatal("Setup from task " + strconv.Itoa(int(key.from)) + " to task " +
+					strconv.Itoa(int(key.to)) + " has unknown task " + strconv.Itoa(int(id)))
+			}
+		}
+	}
+}
+
+// Returns the setup time of the tasks done in the order given by container
+// index, including the setup from the last task back to the first for the next
+// unit
+func (tc *TaskContainer) sequenceSetupTime(seq []int) float64 {
+	if len(seq) < 2 {
+		return 0.0
+	}
+	time := 0.0
+	for i, idx := range seq {
+		next := seq[(i+1)%len(seq)]
+		time += tc.GetSetupTime(tc.tasks[idx].id, tc.tasks[next].id)
+	}
+	return time
+}
+
+// Returns where in the sequence of a station, by container index, the task adds
+// the least setup time and the time it adds. The task has to come after its
+// prereqs at the station, and when in_order is false it can only go last.
+func (tc *TaskContainer) bestInsertion(graph *TaskGraph, seq []int, idx int, in_order bool) (int, float64) {
+	num := len(seq)
+	if num == 0 {
+		return 0, 0.0
+	}
+
+	first_pos := num
+	if in_order {
+		first_pos = 0
+		for pos, other := range seq {
+			for _, prereq_idx := range graph.prereqs[idx] {
+				if other == prereq_idx {
+					first_pos = pos + 1
+				}
+			}
+		}
+	}
+
+	id := tc.tasks[idx].id
+	best_pos, best_added := -1, 0.0
+	for pos := first_pos; pos <= num; pos++ {
+		prev_id, next_id := tc.tasks[seq[(pos+num-1)%num]].id, tc.tasks[seq[pos%num]].id
+		added := tc.GetSetupTime(prev_id, id) + tc.GetSetupTime(id, next_id)
+		if num > 1 {
+			added -= tc.GetSetupTime(prev_id, next_id)
+		}
+		if best_pos == -1 || added < best_added-k_time_epsilon {
+			best_pos, best_added = pos, added
+		}
+	}
+	return best_pos, best_added
+}
+
+// Returns the setup time of each workstation, or side of a two-sided station,
+// with its tasks done in the order of the assignments, nil without setup times
+func (sol *PartialSolution) getOperatorSetups() map[operatorKey]float64 {
+	tc := sol.getTaskContainer()
+	if !tc.HasSetups() {
+		return nil
+	}
+
+	seqs := make(map[operatorKey][]int)
+	for _, asg := range sol.assignments {
+		key := operatorKey{asg.j, sol.sides[asg.i], sol.operators[asg.i]}
+		seqs[key] = append(seqs[key], tc.taskMapping[asg.i])
+	}
+	setups := make(map[operatorKey]float64)
+	for key, seq := range seqs {
+		setups[key] = tc.sequenceSetupTime(seq)
+	}
+	return setups
+}
+
+// Returns the setup time of each station, both sides of a two-sided station
+// together, nil without setup times
+func (sol *PartialSolution) getStationSetups() map[WorkstationId_t]float64 {
+	operator_setups := sol.getOperatorSetups()
+	if operator_setups == nil {
+		return nil
+	}
+	setups := make(map[WorkstationId_t]float64)
+	for key, time := range operator_setups {
+		setups[key.ws] += time
+	}
+	return setups
+}
+
+// Returns the setup time of the solution summed over its stations, with the
+// tasks of each station done in the order of the assignments
+func (sol *PartialSolution) GetSetupTime() float64 {
+	time := 0.0
+	for _, setup := range sol.getOperatorSetups() {
+		time += setup
+	}
+	return time
+}
+
+// Returns an error for the first task done at its station before one of its
+// prereqs, the setup time of a station depends on the order of its tasks
+func (sol *PartialSolution) checkSequence(tc *TaskContainer) error {
+	done := make(map[TaskId_t]bool)
+	station_of := make(map[TaskId_t]WorkstationId_t)
+	for _, asg := range sol.assignments {
+		station_of[asg.i] = asg.j
+	}
+	for _, asg := range sol.assignments {
+		for _, prereq := range tc.tasks[tc.taskMapping[asg.i]].prereqs {
+			if wsid, ok := station_of[prereq]; ok && wsid == asg.j && !done[prereq] {
+				return errors.New("Task " + strconv.Itoa(int(asg.i)) + " is done before its prereq " +
+					strconv.Itoa(int(prereq)) + " at workstation " + strconv.Itoa(int(asg.j)))
+			}
+		}
+		done[asg.i] = true
+	}
+	return nil
+}
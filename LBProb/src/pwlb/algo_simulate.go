@@ -0,0 +1,212 @@
+package pwlb
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// Parameters of a simulation, the same parameters always give the same result
+type SimulationConfig struct {
+	Cycles int // number of units sent down the line
+	Seed   int64
+}
+
+// How one station of a solution fared over the cycles of a simulation
+type StationSimulation struct {
+	station     WorkstationId_t
+	capacity    float64 // cycle time of all copies of the station together
+	meanLoad    float64 // mean of the drawn station times
+	overruns    int     // cycles the station took longer than its capacity
+	bottlenecks int     // cycles the station was the slowest of the line
+}
+
+func (ss *StationSimulation) GetStation() WorkstationId_t {
+	return ss.station
+}
+
+func (ss *StationSimulation) GetMeanLoad() float64 {
+	return ss.meanLoad
+}
+
+func (ss *StationSimulation) GetOverruns() int {
+	return ss.overruns
+}
+
+func (ss *StationSimulation) GetBottlenecks() int {
+	return ss.bottlenecks
+}
+
+// Outcome of a simulation of a solution, see Simulate
+type SimulationResult struct {
+	sol             *PartialSolution
+	cycles          int
+	meanCycleTime   float64 // mean time the line took per unit
+	meanWorkTime    float64 // mean of the drawn task times of a unit summed
+	numWorkstations int     // every copy of a replicated station counted
+	stations        []StationSimulation
+}
+
+func (sr *SimulationResult) GetCycles() int {
+	return sr.cycles
+}
+
+// Returns the result of each station by station id
+func (sr *SimulationResult) GetStations() []StationSimulation {
+	return sr.stations
+}
+
+// Returns the mean time the line took per unit, no less than the cycle time
+func (sr *SimulationResult) GetMeanCycleTime() float64 {
+	return sr.meanCycleTime
+}
+
+// Returns the expected units finished per unit of time
+func (sr *SimulationResult) GetThroughput() float64 {
+	if sr.meanCycleTime == 0.0 {
+		return 0.0
+	}
+	return 1.0 / sr.meanCycleTime
+}
+
+// Returns the share of the throughput planned at the cycle time the line reaches
+func (sr *SimulationResult) GetThroughputShare() float64 {
+	if sr.meanCycleTime == 0.0 {
+		return 0.0
+	}
+	return sr.sol.getTaskContainer().cycleTime / sr.meanCycleTime
+}
+
+// Returns the line efficiency at the simulated pace, the drawn task time of a
+// unit over the time all workstations spent on it. Next to GetLineEfficiency of
+// the solution it shows what the variation of task times costs.
+func (sr *SimulationResult) GetEfficiency() float64 {
+	if sr.numWorkstations == 0 || sr.meanCycleTime == 0.0 {
+		return 0.0
+	}
+	return sr.meanWorkTime / (float64(sr.numWorkstations) * sr.meanCycleTime)
+}
+
+// Returns the share of cycles each station overran its capacity, by station id
+func (sr *SimulationResult) GetOverrunRates() []float64 {
+	rates := make([]float64, len(sr.stations))
+	for i, _ := range sr.stations {
+		rates[i] = float64(sr.stations[i].overruns) / float64(sr.cycles)
+	}
+	return rates
+}
+
+// Returns an error when the tasks of a station don't run one after another, on
+// the two sides of a two-sided line or by several operators
+func (sol *PartialSolution) checkTimedAsWhole(tc *TaskContainer) error {
+	if tc.layout == LayoutTwoSided || sol.sides != nil {
+		return errors.New("Two-sided lines can't be simulated, the sides of a station work at once")
+	}
+	if sol.operators != nil {
+		return errors.New("Multi-manned stations can't be simulated, their operators work at once")
+	}
+	return nil
+}
+
+// Returns the share of cycles each station was the bottleneck, by station id
+func (sr *SimulationResult) GetBottleneckRates() []float64 {
+	rates := make([]float64, len(sr.stations))
+	for i, _ := range sr.stations {
+		rates[i] = float64(sr.stations[i].bottlenecks) / float64(sr.cycles)
+	}
+	return rates
+}
+
+// Sends units down a paced line balanced by the solution, drawing the time of
+// every task of every unit from its distribution. A station overruns when its
+// time exceeds the cycle time of all its copies together. Each cycle the line
+// moves on once the slowest station is done, but never before the cycle time, so
+// overruns stop the whole line. The station that needs the most of its capacity
+// is the bottleneck of the cycle, the first by id on a tie. Stations are timed
+// as a whole, so two-sided lines and multi-manned stations, whose sides and
+// operators work at once, are rejected.
+func Simulate(sol *PartialSolution, cfg SimulationConfig) (*SimulationResult, error) {
+	if cfg.Cycles <= 0 {
+		return nil, errors.New("A simulation needs at least one cycle")
+	}
+	tc := sol.getTaskContainer()
+	if err := sol.checkTimedAsWhole(tc); err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(cfg.Seed))
+
+	// Stations by id with the container indices of their tasks
+	sr := &SimulationResult{sol: sol, cycles: cfg.Cycles}
+	station_idxs := make(map[WorkstationId_t][]int)
+	for _, asg := range sol.assignments {
+		if _, ok := station_idxs[asg.j]; !ok {
+			sr.stations = append(sr.stations, StationSimulation{station: asg.j})
+		}
+		station_idxs[asg.j] = append(station_idxs[asg.j], tc.taskMapping[asg.i])
+	}
+	sort.Slice(sr.stations, func(a, b int) bool {
+		return sr.stations[a].station < sr.stations[b].station
+	})
+	for i, _ := range sr.stations {
+		replicas := sol.GetReplicas(sr.stations[i].station)
+		sr.stations[i].capacity = tc.cycleTime * float64(replicas)
+		sr.numWorkstations += replicas
+	}
+	if len(sr.stations) == 0 {
+		return sr, nil
+	}
+
+	// Setup times don't vary, a station needs them every cycle
+	setups := sol.getStationSetups()
+	total_time, total_work := 0.0, 0.0
+	for cycle := 0; cycle < cfg.Cycles; cycle++ {
+		cycle_time := tc.cycleTime
+		bottleneck, max_use := 0, -1.0
+		for i, _ := range sr.stations {
+			ss := &sr.stations[i]
+			load := setups[ss.station]
+			for _, idx := range station_idxs[ss.station] {
+				load += tc.tasks[idx].sampleTime(rng)
+			}
+			ss.meanLoad += load
+			total_work += load
+			if load > ss.capacity+k_time_epsilon {
+				ss.overruns++
+			}
+
+			// A copy of a replicated station gets one unit in as many cycles as
+			// there are copies
+			use := load / ss.capacity
+			if use > max_use {
+				bottleneck, max_use = i, use
+			}
+			if use*tc.cycleTime > cycle_time {
+				cycle_time = use * tc.cycleTime
+			}
+		}
+		sr.stations[bottleneck].bottlenecks++
+		total_time += cycle_time
+	}
+
+	for i, _ := range sr.stations {
+		sr.stations[i].meanLoad /= float64(cfg.Cycles)
+	}
+	sr.meanCycleTime = total_time / float64(cfg.Cycles)
+	sr.meanWorkTime = total_work / float64(cfg.Cycles)
+	return sr, nil
+}
+
+// Returns a line for each station like
+// "Station 2:      Mean 48.10   Overrun 12.3%   Bottleneck 40.5%"
+func PrettySimulationStr(sr *SimulationResult) string {
+	str := ""
+	overrun_rates, bottleneck_rates := sr.GetOverrunRates(), sr.GetBottleneckRates()
+	for i, _ := range sr.stations {
+		str += "Station " + strconv.Itoa(int(sr.stations[i].station)) + ":      "
+		str += "Mean " + strconv.FormatFloat(sr.stations[i].meanLoad, 'f', 2, 64)
+		str += "   Overrun " + strconv.FormatFloat(overrun_rates[i]*100.0, 'f', 1, 64) + "%"
+		str += "   Bottleneck " + strconv.FormatFloat(bottleneck_rates[i]*100.0, 'f', 1, 64) + "%\n"
+	}
+	return str
+}
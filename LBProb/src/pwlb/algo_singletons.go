@@ -1,6 +1,7 @@
 package pwlb
 
 import (
+	"errors"
 	"log"
 	"strconv"
 	"strings"
@@ -14,9 +15,17 @@ import (
 type TaskContainer struct {
 	tasks       []Task
 	taskMapping map[TaskId_t]int
-
-	// Extra for alternate heuristic
-	postreqs map[TaskId_t]PostReqGraph
+	cycleTime   float64 // time available at each workstation
+	layout      LineLayout
+	models      []ProductModel       // variants built on a mixed-model line, if any
+	zones       []Zone               // tasks that must or must not share a station
+	setups      map[setupKey]float64 // time between two tasks done one after the other
+	workers     []Worker             // crew to assign to the stations, if any
+	equipment   []Equipment          // equipment tasks may need, costed per station it is at
+	stationCost float64              // fixed cost of each station
+
+	// Precedence graph, built on first use
+	graph *TaskGraph
 }
 
 var task_container_instance *TaskContainer
@@ -25,15 +34,33 @@ var task_container_mutex sync.RWMutex
 
 func GetTaskContainer() *TaskContainer {
 	task_container_once.Do(func() {
-		task_container_instance = &TaskContainer{}
-		task_container_instance.taskMapping = make(map[TaskId_t]int)
-
-		// Extra for alternate heuristic
-		task_container_instance.postreqs = make(map[TaskId_t]PostReqGraph)
+		task_container_instance = NewTaskContainer()
 	})
 	return task_container_instance
 }
 
+// Makes an empty container apart from the global one, used when several
+// problems are worked on at once
+func NewTaskContainer() *TaskContainer {
+	return &TaskContainer{taskMapping: make(map[TaskId_t]int), cycleTime: k_cycle_time}
+}
+
+func (tc *TaskContainer) GetCycleTime() float64 {
+	return tc.cycleTime
+}
+
+func (tc *TaskContainer) SetCycleTime(cycle_time float64) {
+	tc.cycleTime = cycle_time
+}
+
+func (tc *TaskContainer) GetLayout() LineLayout {
+	return tc.layout
+}
+
+func (tc *TaskContainer) SetLayout(layout LineLayout) {
+	tc.layout = layout
+}
+
 func (tc *TaskContainer) GetTaskReadOnly(id TaskId_t) Task {
 	some_task := tc.tasks[tc.taskMapping[id]]
 	return some_task
@@ -47,7 +74,16 @@ func (tc *TaskContainer) FillFrom(task_list []string) {
 		// then split into a sequence of substrings that represent task fields
 		trimmed_ele := strings.TrimSpace(ele)
 		task_fields := strings.Split(trimmed_ele, ",")
-		if len(task_fields) != 3 {
+
+		// Lines that don't start with a task id are directives like "model,A,..."
+		if _, err := strconv.Atoi(task_fields[0]); err != nil {
+			if err := tc.parseDirective(task_fields); err != nil {
+				log.Fatal(err.Error() + " in line: " + ele)
+			}
+			continue
+		}
+
+		if len(task_fields) < 3 {
 			log.Fatal("Improper task format in task: " + ele)
 		}
 
@@ -74,19 +110,109 @@ func (tc *TaskContainer) FillFrom(task_list []string) {
 		new_task := NewTask(TaskId_t(id), cost)
 		new_task.prereqs = append(new_task.prereqs, prereqs...)
 
+		// Fields after the prereqs are attributes like "side=L"
+		for _, field := range task_fields[3:] {
+			if err := new_task.setAttr(field); err != nil {
+				log.Fatal(err.Error() + " in task: " + ele)
+			}
+		}
+
 		// Add the new task to the container
 		task_idx := len(tc.tasks)
 		tc.tasks = append(tc.tasks, *new_task)
 		tc.taskMapping[new_task.id] = task_idx
 	}
+
+	// Task times of a mixed-model line are the average over the models
+	if len(tc.models) != 0 {
+		tc.averageModelTimes()
+	}
+	tc.checkZoneTasks()
+	tc.checkSetupTasks()
+	tc.checkWorkerTasks()
+	tc.checkTaskEquipment()
+
+	// Any graph built before no longer covers every task
+	tc.graph = nil
+}
+
+// Parses a spec line that isn't a task, split on commas
+func (tc *TaskContainer) parseDirective(fields []string) error {
+	switch fields[0] {
+	case "model":
+		return tc.parseModel(fields)
+	case "together", "apart":
+		return tc.parseZone(fields)
+	case "setup":
+		return tc.parseSetup(fields)
+	case "worker":
+		return tc.parseWorker(fields)
+	case "equipment":
+		return tc.parseEquipment(fields)
+	case "station_cost":
+		return tc.parseStationCost(fields)
+	}
+	return errors.New("Unknown directive " + fields[0])
+}
+
+// Returns spec lines for everything in the container other than the tasks
+func (tc *TaskContainer) directivesToStrArr() []string {
+	var strs []string
+	for i, _ := range tc.models {
+		strs = append(strs, tc.models[i].ToStr())
+	}
+	for i, _ := range tc.zones {
+		strs = append(strs, tc.zones[i].ToStr())
+	}
+	for _, setup := range tc.GetSetups() {
+		strs = append(strs, setup.ToStr())
+	}
+	for i, _ := range tc.workers {
+		strs = append(strs, tc.workers[i].ToStr())
+	}
+	if tc.stationCost != 0.0 {
+		strs = append(strs, "station_cost,"+costToStr(tc.stationCost))
+	}
+	for i, _ := range tc.equipment {
+		strs = append(strs, tc.equipment[i].ToStr())
+	}
+	return strs
+}
+
+// Returns the precedence graph of the tasks, building it the first time. Exits
+// when the prereqs can't be built into a graph, see CheckGraph.
+func (tc *TaskContainer) GetGraph() *TaskGraph {
+	if err := tc.CheckGraph(); err != nil {
+		log.Fatal(err)
+	}
+	return tc.graph
+}
+
+// Builds the precedence graph of the tasks if it isn't built yet, failing when a
+// prereq is unknown or the prereqs form a cycle
+func (tc *TaskContainer) CheckGraph() error {
+	if tc.graph == nil {
+		graph, err := NewTaskGraph(tc)
+		if err != nil {
+			return err
+		}
+		tc.graph = graph
+	}
+	return nil
 }
 
 func (tc *TaskContainer) Clear() {
 	tc.tasks = nil
 	tc.taskMapping = make(map[TaskId_t]int)
-
-	// Extra for alternate heuristic
-	tc.postreqs = make(map[TaskId_t]PostReqGraph)
+	tc.cycleTime = k_cycle_time
+	tc.layout = LayoutStraight
+	tc.models = nil
+	tc.zones = nil
+	tc.setups = nil
+	tc.workers = nil
+	tc.equipment = nil
+	tc.stationCost = 0.0
+	tc.graph = nil
 }
 
 func (tc *TaskContainer) ToStrArr() []string {
@@ -94,7 +220,7 @@ func (tc *TaskContainer) ToStrArr() []string {
 	for _, ele := range tc.tasks {
 		strs = append(strs, ele.ToStr())
 	}
-	return strs
+	return append(strs, tc.directivesToStrArr()...)
 }
 
 ///////////////////////////////////
@@ -143,6 +269,19 @@ func (wsc *WorkstationContainer) clearAllStationsTasks() {
 	}
 }
 
+// Replaces the tasks of every station with the assignments of a solution, adding
+// any station the solution uses that isn't in the container yet
+func (wsc *WorkstationContainer) FillFromSolution(sol *PartialSolution) {
+	wsc.clearAllStationsTasks()
+	for _, asg := range sol.assignments {
+		if _, ok := wsc.workstations[asg.j]; !ok {
+			wsc.workstations[asg.j] = NewWorkstation(asg.j)
+		}
+		wstasks := &wsc.workstations[asg.j].tasks
+		*wstasks = append(*wstasks, asg.i)
+	}
+}
+
 func (wsc *WorkstationContainer) ToStrArr() []string {
 	var strs []string
 	for _, ele := range wsc.workstations {
@@ -150,47 +289,3 @@ func (wsc *WorkstationContainer) ToStrArr() []string {
 	}
 	return strs
 }
-
-///////////////////////////////////
-// TaskContainer Extra for alternate heuristic
-///////////////////////////////////
-
-func buildPostReqGraphs() {
-	tc := GetTaskContainer()
-
-	// Iterating front to back we establish the first link in all postreq chains
-	for i, _ := range tc.tasks {
-		for _, prereqId := range tc.tasks[i].prereqs {
-			new_node := PostReqNode{tc.tasks[i].id, nil}
-			tc.postreqs[prereqId] = append(tc.postreqs[prereqId], &new_node)
-		}
-	}
-
-	// Iterating back to front we append current task's postreq nodes onto any task
-	// that has the current task as the first postreq
-	num_tasks := len(tc.tasks)
-	for i := num_tasks - 1; i >= 0; i-- {
-		cur_task := &tc.tasks[i]
-
-		// Get all the prereq node for the current node, the current should be
-		// in the postreqs for each prereq node
-		for _, cur_prereqId := range cur_task.prereqs {
-			// Get the postreq nodes to append
-			for j, _ := range tc.postreqs[cur_prereqId] {
-				cur_prereq_postreq_node := tc.postreqs[cur_prereqId][j]
-
-				// skip nodes that don't map to the current task
-				if cur_prereq_postreq_node.task_id != cur_task.id {
-					continue
-				}
-
-				// append the current tasks postreq graph onto the node in the prereq's
-				// postreq graph representing the current node.
-				for k, _ := range tc.postreqs[cur_task.id] {
-					cur_prereq_postreq_node.branches = append(cur_prereq_postreq_node.branches,
-						(tc.postreqs[cur_task.id])[k])
-				}
-			}
-		}
-	}
-}
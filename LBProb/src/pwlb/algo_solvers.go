@@ -0,0 +1,307 @@
+package pwlb
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How a solve ended
+type SolveStatus int
+
+const (
+	SolveCompleted   SolveStatus = iota // ran to the end, the solution may not be optimal
+	SolveOptimal                        // the solution meets a lower bound so can't be beaten
+	SolveInterrupted                    // the context ended first, the solution is the best so far
+)
+
+var solve_status_names = map[SolveStatus]string{
+	SolveCompleted:   "completed",
+	SolveOptimal:     "optimal",
+	SolveInterrupted: "interrupted",
+}
+
+func (status SolveStatus) ToStr() string {
+	return solve_status_names[status]
+}
+
+// The best solution a solver found and how the solve ended
+type SolveResult struct {
+	sol    *PartialSolution
+	status SolveStatus
+}
+
+func (sr *SolveResult) GetSolution() *PartialSolution {
+	return sr.sol
+}
+
+func (sr *SolveResult) GetStatus() SolveStatus {
+	return sr.status
+}
+
+// Makes the result of a solve that ran to the end, marking it optimal when the
+// number of stations meets the lower bound
+func newCompletedResult(sol *PartialSolution) *SolveResult {
+	if sol.GetMeasuredMin() <= sol.getTaskContainer().GetLowerBound() {
+		return &SolveResult{sol, SolveOptimal}
+	}
+	return &SolveResult{sol, SolveCompleted}
+}
+
+///////////////////////////////////
+// Solve progress
+///////////////////////////////////
+
+// What a progress event reports
+type SolveEventKind int
+
+const (
+	EventIncumbent SolveEventKind = iota // a solution better than any before was found
+	EventBound                           // the lower bound on stations went up
+	EventIteration                       // the solver finished another step of its search
+)
+
+// A snapshot of a running solve passed to an observer. The fields not related to
+// the kind of event hold their latest values, so every event can be shown alone.
+type SolveEvent struct {
+	kind       SolveEventKind
+	incumbent  *PartialSolution // nil until the first solution is found
+	lowerBound int
+	iterations int
+	elapsed    time.Duration
+}
+
+func (ev *SolveEvent) GetKind() SolveEventKind {
+	return ev.kind
+}
+
+func (ev *SolveEvent) GetIncumbent() *PartialSolution {
+	return ev.incumbent
+}
+
+func (ev *SolveEvent) GetLowerBound() int {
+	return ev.lowerBound
+}
+
+func (ev *SolveEvent) GetIterations() int {
+	return ev.iterations
+}
+
+func (ev *SolveEvent) GetElapsed() time.Duration {
+	return ev.elapsed
+}
+
+// Called by a solver as it makes progress, on the goroutine running the solver.
+// The incumbent must not be changed by the observer.
+type SolveObserver func(ev *SolveEvent)
+
+// Optional settings of a solve, a nil options value means the defaults
+type SolveOptions struct {
+	Observer SolveObserver
+
+	// Most parallel copies of a station, a task longer than the cycle time gets a
+	// station with as many copies as it needs when this is above one. Only the
+	// SST, RPW, SA and GA solvers on a straight or U-line replicate stations.
+	MaxReplicas int
+	ReplicaCost float64 // cost of each copy beyond the first, a station costs one
+
+	// Chance each station has to finish within the cycle time when task times
+	// vary, zero to only fit the mean task times. Stations are filled so that the
+	// normal approximation of their load meets it. It must be at least 0.5, see
+	// CheckServiceLevel, and two-sided lines don't support it.
+	ServiceLevel float64
+
+	shared *sharedBounds                // set by a portfolio to share bounds between its solvers
+	homes  map[TaskId_t]WorkstationId_t // set by Rebalance to the stations tasks had before
+}
+
+// The fewest stations of any solution found and the highest lower bound known by
+// the solvers of a portfolio, safe to use from several goroutines
+type sharedBounds struct {
+	mutex      sync.Mutex
+	stations   int // zero until a solution is found
+	lowerBound int
+	proven     bool // an exact search showed no solution has fewer stations
+}
+
+// Records the number of stations of a solution, returns true if it is the best
+func (sb *sharedBounds) offer(stations int) bool {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	if sb.stations != 0 && stations >= sb.stations {
+		return false
+	}
+	sb.stations = stations
+	return true
+}
+
+// Raises the lower bound, returns true if it went up
+func (sb *sharedBounds) raise(lower_bound int) bool {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	if lower_bound <= sb.lowerBound {
+		return false
+	}
+	sb.lowerBound = lower_bound
+	return true
+}
+
+func (sb *sharedBounds) prove() {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	sb.proven = true
+}
+
+// Returns the fewest stations found or zero, the lower bound and whether the
+// stations found are known to be optimal
+func (sb *sharedBounds) get() (int, int, bool) {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	return sb.stations, sb.lowerBound, sb.proven || (sb.stations != 0 && sb.stations <= sb.lowerBound)
+}
+
+// Keeps the latest state of a solve and passes it to the observer of the options,
+// doing nothing when there isn't one. Incumbents and bounds are also shared with
+// the other solvers when the solve is part of a portfolio.
+type solveProgress struct {
+	observer SolveObserver
+	shared   *sharedBounds
+	start    time.Time
+	state    SolveEvent
+}
+
+func newSolveProgress(opts *SolveOptions) *solveProgress {
+	progress := &solveProgress{start: time.Now()}
+	if opts != nil {
+		progress.observer = opts.Observer
+		progress.shared = opts.shared
+	}
+	if progress.shared == nil {
+		progress.shared = &sharedBounds{}
+	}
+	return progress
+}
+
+func (progress *solveProgress) notify(kind SolveEventKind) {
+	if progress.observer == nil {
+		return
+	}
+	ev := progress.state
+	ev.kind = kind
+	ev.elapsed = time.Since(progress.start)
+	progress.observer(&ev)
+}
+
+func (progress *solveProgress) incumbent(sol *PartialSolution) {
+	progress.shared.offer(sol.GetMeasuredMin())
+	progress.state.incumbent = sol
+	progress.notify(EventIncumbent)
+}
+
+func (progress *solveProgress) bound(lower_bound int) {
+	progress.shared.raise(lower_bound)
+	if lower_bound <= progress.state.lowerBound {
+		return
+	}
+	progress.state.lowerBound = lower_bound
+	progress.notify(EventBound)
+}
+
+func (progress *solveProgress) iteration() {
+	progress.state.iterations++
+	progress.notify(EventIteration)
+}
+
+///////////////////////////////////
+// Solver registry
+///////////////////////////////////
+
+// A solver assigns the tasks of a container to stations without using the global
+// containers, so several may run at once on different containers. Solvers stop
+// soon after the context is cancelled or its deadline passes and return the best
+// solution found by then. The options may be nil.
+type SolverFunc func(ctx context.Context, tc *TaskContainer, opts *SolveOptions) *SolveResult
+
+var solvers = map[string]SolverFunc{
+	"bb":   SolveBranchAndBound,
+	"cost": SolveCost,
+	"ga":   SolveGenetic,
+	"rpw":  SolveRPW,
+	"sa":   SolveAnnealing,
+	"sst":  SolveSST,
+}
+
+// Returns false for a solver that can't solve the problem in the container with
+// the options, see CheckSolver
+func solverSupports(name string, tc *TaskContainer, opts *SolveOptions) bool {
+	return CheckSolver(name, tc, opts) == nil
+}
+
+// Returns an error saying why the named solver can't solve the problem in the
+// container with the options, nil when it can. Branch and bound neither solves
+// two-sided lines nor replicates stations for tasks longer than the cycle time.
+// The cost solver only solves plain straight lines, see SolveCost.
+func CheckSolver(name string, tc *TaskContainer, opts *SolveOptions) error {
+	if name == k_cost_solver_name {
+		return checkCostSolver(tc, opts)
+	}
+	if name != "bb" {
+		return nil
+	}
+	if tc.layout == LayoutTwoSided {
+		return errors.New("Branch and bound doesn't solve two-sided lines, use another solver")
+	}
+	for i, _ := range tc.tasks {
+		if tc.tasks[i].cost > tc.cycleTime && opts.getMaxReplicas() > 1 {
+			return errors.New("Branch and bound doesn't replicate stations for task " +
+				tc.tasks[i].ToStr() + " over the cycle time, use another solver")
+		}
+	}
+	return nil
+}
+
+// Runs every registered solver at once, see NewPortfolioSolver
+const k_portfolio_name = "portfolio"
+
+// Solver that minimizes the line cost rather than the stations, left out of the
+// portfolio that keeps the solution with the fewest stations
+const k_cost_solver_name = "cost"
+
+// Returns the solver registered under a name like "sst", or a portfolio of all
+// of them but the cost solver for "portfolio"
+func GetSolver(name string) (SolverFunc, error) {
+	if name == k_portfolio_name {
+		var names []string
+		for _, solver_name := range registeredSolverNames() {
+			if solver_name != k_cost_solver_name {
+				names = append(names, solver_name)
+			}
+		}
+		return NewPortfolioSolver(names)
+	}
+
+	solver, ok := solvers[name]
+	if !ok {
+		return nil, errors.New("Unknown solver " + name + ", expected one of " +
+			strings.Join(GetSolverNames(), ", "))
+	}
+	return solver, nil
+}
+
+// Returns the names of all registered solvers in alphabetical order followed by
+// "portfolio"
+func GetSolverNames() []string {
+	return append(registeredSolverNames(), k_portfolio_name)
+}
+
+func registeredSolverNames() []string {
+	var names []string
+	for name, _ := range solvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
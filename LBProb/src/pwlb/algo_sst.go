@@ -0,0 +1,475 @@
+package pwlb
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+///////////////////////////////////
+// Heap of available tasks
+///////////////////////////////////
+
+// Container indices of tasks ordered by a priority rule, the task that should be
+// assigned next is on top
+type taskHeap struct {
+	idxs []int
+	less func(lhs, rhs int) bool
+}
+
+func (h *taskHeap) Len() int {
+	return len(h.idxs)
+}
+
+func (h *taskHeap) Less(i, j int) bool {
+	return h.less(h.idxs[i], h.idxs[j])
+}
+
+func (h *taskHeap) Swap(i, j int) {
+	h.idxs[i], h.idxs[j] = h.idxs[j], h.idxs[i]
+}
+
+func (h *taskHeap) Push(x interface{}) {
+	h.idxs = append(h.idxs, x.(int))
+}
+
+func (h *taskHeap) Pop() interface{} {
+	last := len(h.idxs) - 1
+	idx := h.idxs[last]
+	h.idxs = h.idxs[:last]
+	return idx
+}
+
+///////////////////////////////////
+// Station oriented SST
+///////////////////////////////////
+
+// Assigns the tasks in the container to stations with the SST heuristic. Stations
+// are filled one at a time, each taking the shortest available task that still
+// fits until none does. A task becomes available once its last prereq is assigned,
+// which is tracked with a counter of unassigned prereqs per task, so the solve is
+// O(n log n) plus the size of the graph. Ties in task time are broken as the
+// original solver broke them, see sstLess. The global containers are not used.
+//
+// On a U-line a task also becomes available once its last postreq is assigned,
+// it is then done on the back side of the station unless its prereqs are all
+// assigned too.
+//
+// The context is checked before each station is opened. If it has ended the
+// stations filled so far are returned as an interrupted result, every task in
+// them has its prereqs assigned but the remaining tasks are not.
+//
+// Tasks zoned together are solved as one task, see solveZoned, and a task never
+// joins a station holding a task it is zoned apart from. Pinned tasks only go to
+// the stations of their pins, see getPinWindows, and the tasks that can't go
+// after the open station are taken before the others. At a service level a
+// station only takes a task if its load then meets it, see stationTimeNeeded, and
+// a task too uncertain for a station of its own is handled like one longer than
+// the cycle time. With setup times a station only takes a task if it still fits
+// with the setups it adds, and the task is put where in the sequence of the
+// station it adds the least, after its prereqs. When rebalancing tasks are kept
+// at the stations they had before where they can, see Rebalance.
+//
+// An observer is told the lower bound first, then gets an iteration for each
+// station filled and the solution once all tasks are assigned.
+func SolveSST(ctx context.Context, tc *TaskContainer, opts *SolveOptions) *SolveResult {
+	if tc.hasTogetherZones() {
+		return solveZoned(ctx, tc, opts, SolveSST)
+	}
+	return solveStationOriented(ctx, tc, opts, sstLess(tc), true)
+}
+
+// Returns the SST priority rule, shorter tasks first. Ties go the way sorting the
+// listed tasks on task time puts them, as the original scan did, so the rewrite
+// makes the same assignments.
+func sstLess(tc *TaskContainer) func(lhs, rhs int) bool {
+	order := make([]int, len(tc.tasks))
+	for i, _ := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return tc.tasks[order[a]].cost < tc.tasks[order[b]].cost
+	})
+	rank := make([]int, len(order))
+	for pos, idx := range order {
+		rank[idx] = pos
+	}
+
+	return func(lhs, rhs int) bool {
+		if tc.tasks[lhs].cost != tc.tasks[rhs].cost {
+			return tc.tasks[lhs].cost < tc.tasks[rhs].cost
+		}
+		return rank[lhs] < rank[rhs]
+	}
+}
+
+// Fills stations one at a time with the available task that comes first by the
+// priority rule until none fits, as described for SolveSST. The rule compares
+// container indices and must never find two tasks equal. If it puts shorter tasks
+// first a station is full as soon as the top task doesn't fit, otherwise the
+// tasks that don't fit are set aside until the next station. Two-sided lines are
+// left to solveTwoSided. Stations are replicated as the options allow.
+func solveStationOriented(ctx context.Context, tc *TaskContainer, opts *SolveOptions,
+	less func(lhs, rhs int) bool, shortest_first bool) *SolveResult {
+	if tc.layout == LayoutTwoSided {
+		return solveTwoSided(ctx, tc, opts, less)
+	}
+
+	sol := &PartialSolution{tc: tc}
+	num_tasks := len(tc.tasks)
+	if num_tasks == 0 {
+		return newCompletedResult(sol)
+	}
+	graph := tc.GetGraph()
+
+	progress := newSolveProgress(opts)
+	progress.bound(tc.GetLowerBound())
+
+	// Problem writeup states that workstations and tasks start with common index
+	cur_ws_id := WorkstationId_t(tc.tasks[0].id)
+	cur_load := 0.0
+	cur_variance := 0.0
+	capacity := tc.cycleTime
+
+	// At a service level a task may fit by its mean time and still be too likely
+	// to overrun the station, a longer task with less variance may fit instead
+	z := opts.getServiceZ()
+	if z != 0.0 {
+		shortest_first = false
+	}
+
+	// With setup times a task takes its own time and the setups it adds where it
+	// is put in the sequence of the station, so a shorter task may add more
+	setups := tc.HasSetups()
+	if setups {
+		shortest_first = false
+	}
+
+	// With pins the tasks that can't go after the open station come first, so the
+	// heap no longer puts shorter tasks first
+	pins, err := tc.getPinWindows(opts)
+	if err != nil {
+		panic(err.Error())
+	}
+	homes := newStationHomes(tc, opts)
+	if homes != nil {
+		less = homes.homeFirst(less, &cur_ws_id)
+		shortest_first = false
+	}
+	if pins != nil {
+		less = pins.dueFirst(less, &cur_ws_id)
+		shortest_first = false
+	}
+	avail := &taskHeap{less: less}
+
+	// Number of unassigned prereqs of each task, a task is available at zero. On a
+	// U-line the same is kept for postreqs and a task is available when either
+	// count is zero, queued keeps it from going on the heap twice.
+	u_line := tc.layout == LayoutU
+	indegree := make([]int, num_tasks)
+	outdegree := make([]int, num_tasks)
+	queued := make([]bool, num_tasks)
+	for i, _ := range tc.tasks {
+		indegree[i] = len(graph.prereqs[i])
+		if u_line {
+			outdegree[i] = len(graph.postreqs[i])
+		}
+		if indegree[i] == 0 || (u_line && outdegree[i] == 0) {
+			avail.idxs = append(avail.idxs, i)
+			queued[i] = true
+		}
+	}
+	heap.Init(avail)
+	if u_line {
+		sol.back = make(map[TaskId_t]bool)
+	}
+
+	// Tasks at the open station in the order they are done, kept for the tasks
+	// zoned apart and the setup times. A task is blocked from the open station by
+	// those, by its pin, by the service level, by the setups it adds and, when
+	// rebalancing, by a later station it had before.
+	apart := tc.apartIdxs()
+	at_station := make([]bool, num_tasks)
+	var station_idxs []int
+	station_start := 0
+	var blocked func(idx int) bool
+	if apart != nil || pins != nil || homes != nil || z != 0.0 || setups {
+		blocked = func(idx int) bool {
+			if !pins.allows(idx, cur_ws_id) || !homes.allows(idx, cur_ws_id) {
+				return true
+			}
+			added := tc.tasks[idx].cost
+			if setups {
+				_, setup := tc.bestInsertion(graph, station_idxs, idx, !u_line)
+				added += setup
+			}
+			sd := tc.tasks[idx].sd
+			if stationTimeNeeded(cur_load+added, cur_variance+sd*sd, z) > capacity+k_time_epsilon {
+				return true
+			}
+			if apart != nil {
+				for _, other := range apart[idx] {
+					if at_station[other] {
+						return true
+					}
+				}
+			}
+			return false
+		}
+	}
+
+	if ctx.Err() != nil {
+		return &SolveResult{sol, SolveInterrupted}
+	}
+
+	var skipped []int
+	sol.assignments = make([]TaskAssignment, 0, num_tasks)
+	for len(sol.assignments) != num_tasks {
+		// Take the first task by priority that fits, the ones passed over go back
+		// on the heap once the station is full
+		if idx, ok := popFitting(avail, tc, capacity-cur_load, shortest_first, blocked, &skipped); ok {
+			sol.assignments = append(sol.assignments, TaskAssignment{tc.tasks[idx].id, cur_ws_id})
+			cur_load += tc.tasks[idx].cost
+			cur_variance += tc.tasks[idx].sd * tc.tasks[idx].sd
+			at_station[idx] = true
+			if setups {
+				// The station does its tasks in the order of its assignments
+				pos, setup := tc.bestInsertion(graph, station_idxs, idx, !u_line)
+				cur_load += setup
+				station_idxs = append(station_idxs[:pos], append([]int{idx}, station_idxs[pos:]...)...)
+				for k, station_idx := range station_idxs {
+					sol.assignments[station_start+k].i = tc.tasks[station_idx].id
+				}
+			} else {
+				station_idxs = append(station_idxs, idx)
+			}
+			if indegree[idx] != 0 {
+				sol.back[tc.tasks[idx].id] = true
+			}
+
+			for _, post_idx := range graph.postreqs[idx] {
+				indegree[post_idx]--
+				if indegree[post_idx] == 0 && !queued[post_idx] {
+					heap.Push(avail, post_idx)
+					queued[post_idx] = true
+				}
+			}
+			if u_line {
+				for _, prereq_idx := range graph.prereqs[idx] {
+					outdegree[prereq_idx]--
+					if outdegree[prereq_idx] == 0 && !queued[prereq_idx] {
+						heap.Push(avail, prereq_idx)
+						queued[prereq_idx] = true
+					}
+				}
+			}
+			continue
+		}
+
+		for _, idx := range skipped {
+			heap.Push(avail, idx)
+		}
+		skipped = skipped[:0]
+
+		// A task too long for an empty station gets a station with enough copies
+		// to fit it, when the options allow. A station already replicated that
+		// still takes nothing can't be helped.
+		if cur_load == 0.0 {
+			// Rather than leave a station empty a task is taken from a later one
+			if homes.unblock() {
+				continue
+			}
+			top_task := &tc.tasks[avail.idxs[0]]
+			time_needed := stationTimeNeeded(top_task.cost, top_task.sd*top_task.sd, z)
+			if time_needed <= capacity+k_time_epsilon {
+				pins.checkEmpty(tc, avail, cur_ws_id)
+				panic("Task " + top_task.ToStr() + " fits workstation " + strconv.Itoa(int(cur_ws_id)) +
+					" but can't go to it")
+			}
+			if capacity != tc.cycleTime {
+				panic("Task " + top_task.ToStr() + " doesn't fit workstation " + strconv.Itoa(int(cur_ws_id)) +
+					" with its " + strconv.Itoa(sol.replicas[cur_ws_id]) + " copies")
+			}
+			replicas := tc.replicasNeeded(time_needed)
+			if replicas > opts.getMaxReplicas() {
+				panic(tc.unfitTaskStr(avail.idxs[0], opts.getMaxReplicas(), opts))
+			}
+			if sol.replicas == nil {
+				sol.replicas = make(map[WorkstationId_t]int)
+			}
+			sol.replicas[cur_ws_id] = replicas
+			capacity = tc.cycleTime * float64(replicas)
+			continue
+		}
+
+		pins.checkClosed(tc, avail, cur_ws_id)
+
+		progress.iteration()
+		if ctx.Err() != nil {
+			return &SolveResult{sol, SolveInterrupted}
+		}
+
+		cur_ws_id++
+		cur_load = 0.0
+		cur_variance = 0.0
+		capacity = tc.cycleTime
+		for _, idx := range station_idxs {
+			at_station[idx] = false
+		}
+		station_idxs = station_idxs[:0]
+		station_start = len(sol.assignments)
+		if homes != nil {
+			homes.unblocked = false
+		}
+		if pins != nil || homes != nil {
+			heap.Init(avail)
+		}
+	}
+
+	progress.iteration()
+	progress.incumbent(sol)
+	return newCompletedResult(sol)
+}
+
+// Pops the first task by priority that takes no more than the free time of the
+// station and isn't blocked from it. Tasks on top that don't fit are moved to
+// skipped unless the heap puts shorter tasks first, then the rest can't fit
+// either. Blocked tasks are always moved to skipped.
+func popFitting(avail *taskHeap, tc *TaskContainer, free float64, shortest_first bool,
+	blocked func(idx int) bool, skipped *[]int) (int, bool) {
+	for avail.Len() != 0 {
+		top := avail.idxs[0]
+		if tc.tasks[top].cost <= free {
+			if blocked == nil || !blocked(top) {
+				return heap.Pop(avail).(int), true
+			}
+		} else if shortest_first {
+			break
+		}
+		*skipped = append(*skipped, heap.Pop(avail).(int))
+	}
+	return -1, false
+}
+
+// Returns the assignments as a string like "0:0 1 2 3,1:4" listing station ids
+// followed by their tasks in assignment order
+func (sol *PartialSolution) ToStationsStr() string {
+	str := ""
+	for i, asg := range sol.assignments {
+		if i == 0 || sol.assignments[i-1].j != asg.j {
+			if i != 0 {
+				str += ","
+			}
+			str += strconv.Itoa(int(asg.j)) + ":"
+		} else {
+			str += " "
+		}
+		str += strconv.Itoa(int(asg.i))
+	}
+	return str
+}
+
+// Returns the solution one station per line as ParseSolution reads it, like
+// "2:3 4". A replicated station is written with its copies like "2x3:3 4", a task
+// on the back side of a U-line like "4b" and a task of a two-sided line with its
+// side and start like "4L@12.5".
+func (sol *PartialSolution) ToSolutionLines() []string {
+	var lines []string
+	for i, asg := range sol.assignments {
+		if i == 0 || sol.assignments[i-1].j != asg.j {
+			line := strconv.Itoa(int(asg.j))
+			if replicas := sol.GetReplicas(asg.j); replicas > 1 {
+				line += "x" + strconv.Itoa(replicas)
+			}
+			lines = append(lines, line+":")
+		} else {
+			lines[len(lines)-1] += " "
+		}
+		task_str := strconv.Itoa(int(asg.i))
+		if sol.back[asg.i] {
+			task_str += "b"
+		}
+		if side, ok := sol.sides[asg.i]; ok {
+			task_str += side.ToStr() + "@" + strconv.FormatFloat(sol.starts[asg.i], 'f', -1, 64)
+		}
+		lines[len(lines)-1] += task_str
+	}
+	return lines
+}
+
+// Reads a solution of the tasks in the container written by ToSolutionLines or
+// ToStationsStr, each line may hold one or more stations like "1:1 2,2x2:3 4b".
+// The solution isn't checked, see Validate.
+func ParseSolution(tc *TaskContainer, lines []string) (*PartialSolution, error) {
+	sol := &PartialSolution{tc: tc}
+	for _, line := range lines {
+		for _, station_str := range strings.Split(line, ",") {
+			if strings.TrimSpace(station_str) == "" {
+				continue
+			}
+			ws_tasks := strings.SplitN(station_str, ":", 2)
+			ws_replicas := strings.SplitN(strings.TrimSpace(ws_tasks[0]), "x", 2)
+			wsid, err := strconv.Atoi(ws_replicas[0])
+			if err != nil || len(ws_tasks) != 2 {
+				return nil, errors.New("Improper station " + station_str + " in solution")
+			}
+			if len(ws_replicas) == 2 {
+				replicas, err := strconv.Atoi(ws_replicas[1])
+				if err != nil || replicas < 1 {
+					return nil, errors.New("Improper station " + station_str + " in solution")
+				}
+				if sol.replicas == nil {
+					sol.replicas = make(map[WorkstationId_t]int)
+				}
+				sol.replicas[WorkstationId_t(wsid)] = replicas
+			}
+			for _, task_str := range strings.Fields(ws_tasks[1]) {
+				id, err := sol.parseSolutionTask(task_str)
+				if err != nil {
+					return nil, err
+				}
+				if _, ok := tc.taskMapping[id]; !ok {
+					return nil, errors.New("Unknown task " + strconv.Itoa(int(id)) + " in solution")
+				}
+				sol.assignments = append(sol.assignments, TaskAssignment{id, WorkstationId_t(wsid)})
+			}
+		}
+	}
+	return sol, nil
+}
+
+// Reads a task of a station like "4", "4b" or "4L@12.5" and keeps its back flag
+// or its side and start
+func (sol *PartialSolution) parseSolutionTask(task_str string) (TaskId_t, error) {
+	idstr := strings.TrimSuffix(task_str, "b")
+	side_str, start_str := "", ""
+	if at := strings.Index(task_str, "@"); at > 0 {
+		idstr, side_str, start_str = task_str[:at-1], task_str[at-1:at], task_str[at+1:]
+	}
+	id, err := strconv.Atoi(idstr)
+	if err != nil {
+		return 0, errors.New("Improper task id " + task_str + " in solution")
+	}
+	if idstr != task_str && side_str == "" {
+		if sol.back == nil {
+			sol.back = make(map[TaskId_t]bool)
+		}
+		sol.back[TaskId_t(id)] = true
+	}
+	if side_str != "" {
+		side, err := ParseTaskSide(side_str)
+		start, start_err := strconv.ParseFloat(start_str, 64)
+		if err != nil || side == SideEither || start_err != nil {
+			return 0, errors.New("Improper task " + task_str + " in solution")
+		}
+		if sol.sides == nil {
+			sol.sides = make(map[TaskId_t]TaskSide)
+			sol.starts = make(map[TaskId_t]float64)
+		}
+		sol.sides[TaskId_t(id)], sol.starts[TaskId_t(id)] = side, start
+	}
+	return TaskId_t(id), nil
+}
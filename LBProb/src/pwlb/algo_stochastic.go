@@ -0,0 +1,191 @@
+package pwlb
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// Returns the standard deviation of the task time, zero for a task that always
+// takes its time
+func (t *Task) GetSD() float64 {
+	return t.sd
+}
+
+// Distribution of a task time around its cost, with the standard deviation of the
+// task. Only simulations draw from it, solving to a service level takes every
+// task time as normal.
+type TaskTimeDist int
+
+const (
+	TimeNormal    TaskTimeDist = iota // normal, a draw below zero counts as zero
+	TimeLognormal                     // skewed to the right, never below zero
+	TimeUniform                       // even between the cost less and plus sqrt(3) SDs
+)
+
+var task_time_dist_names = map[string]TaskTimeDist{
+	"normal":    TimeNormal,
+	"lognormal": TimeLognormal,
+	"uniform":   TimeUniform,
+}
+
+func ParseTaskTimeDist(name string) (TaskTimeDist, error) {
+	dist, ok := task_time_dist_names[name]
+	if !ok {
+		return TimeNormal, errors.New("Unknown task time distribution " + name +
+			", expected normal, lognormal or uniform")
+	}
+	return dist, nil
+}
+
+func (dist TaskTimeDist) ToStr() string {
+	for name, named_dist := range task_time_dist_names {
+		if named_dist == dist {
+			return name
+		}
+	}
+	return "?"
+}
+
+func (t *Task) GetDist() TaskTimeDist {
+	return t.dist
+}
+
+// Draws a time of the task from its distribution, its cost when it doesn't vary
+func (t *Task) sampleTime(rng *rand.Rand) float64 {
+	if t.sd == 0.0 || t.cost <= 0.0 {
+		return t.cost
+	}
+	switch t.dist {
+	case TimeLognormal:
+		sigma2 := math.Log1p(t.sd * t.sd / (t.cost * t.cost))
+		return math.Exp(math.Log(t.cost) - sigma2/2.0 + math.Sqrt(sigma2)*rng.NormFloat64())
+	case TimeUniform:
+		half_width := math.Sqrt(3.0) * t.sd
+		return math.Max(0.0, t.cost-half_width+2.0*half_width*rng.Float64())
+	}
+	return math.Max(0.0, t.cost+t.sd*rng.NormFloat64())
+}
+
+// Returns true if some task time varies
+func (tc *TaskContainer) HasStochasticTimes() bool {
+	for i, _ := range tc.tasks {
+		if tc.tasks[i].sd != 0.0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks a service level given in the options, zero for none
+func CheckServiceLevel(service_level float64) error {
+	if service_level != 0.0 && (service_level < 0.5 || service_level >= 1.0) {
+		return errors.New("Service level " + strconv.FormatFloat(service_level, 'f', -1, 64) +
+			" must be at least 0.5 and below 1")
+	}
+	return nil
+}
+
+// Returns the number of standard deviations over the mean a station load may take
+// at the service level of the options, zero when stations only have to fit their
+// mean load
+func (opts *SolveOptions) getServiceZ() float64 {
+	if opts == nil || opts.ServiceLevel == 0.0 {
+		return 0.0
+	}
+	return math.Sqrt2 * math.Erfinv(2.0*opts.ServiceLevel-1.0)
+}
+
+// Returns the time a station with the given mean load and load variance needs to
+// finish within at the service level, by the normal approximation of the sum of
+// its task times
+func stationTimeNeeded(mean, variance, z float64) float64 {
+	return mean + z*math.Sqrt(variance)
+}
+
+// Returns the probability a load with the given mean and variance is done within
+// the capacity, by the normal approximation
+func completionProbability(mean, variance, capacity float64) float64 {
+	if variance == 0.0 {
+		if mean <= capacity+k_time_epsilon {
+			return 1.0
+		}
+		return 0.0
+	}
+	return 0.5 * math.Erfc(-(capacity-mean)/math.Sqrt(2.0*variance))
+}
+
+// The chance one station finishes its tasks within its cycle time
+type StationCompletion struct {
+	station     WorkstationId_t
+	mean        float64 // sum of the mean task times and setup times
+	sd          float64 // standard deviation of the sum of the task times
+	probability float64
+}
+
+func (sc *StationCompletion) GetStation() WorkstationId_t {
+	return sc.station
+}
+
+func (sc *StationCompletion) GetMean() float64 {
+	return sc.mean
+}
+
+func (sc *StationCompletion) GetSD() float64 {
+	return sc.sd
+}
+
+func (sc *StationCompletion) GetProbability() float64 {
+	return sc.probability
+}
+
+// Returns the chance each station of the solution finishes within its cycle time,
+// all copies of a replicated station together, by station id. Task times are
+// taken as independent so the variances of a station add up.
+func (sol *PartialSolution) GetStationCompletions() []StationCompletion {
+	tc := sol.getTaskContainer()
+	means := make(map[WorkstationId_t]float64)
+	variances := make(map[WorkstationId_t]float64)
+	for _, asg := range sol.assignments {
+		_task := &tc.tasks[tc.taskMapping[asg.i]]
+		means[asg.j] += _task.cost
+		variances[asg.j] += _task.sd * _task.sd
+	}
+	for wsid, setup := range sol.getStationSetups() {
+		means[wsid] += setup
+	}
+
+	var completions []StationCompletion
+	for wsid, mean := range means {
+		capacity := tc.cycleTime * float64(sol.GetReplicas(wsid))
+		completions = append(completions, StationCompletion{wsid, mean, math.Sqrt(variances[wsid]),
+			completionProbability(mean, variances[wsid], capacity)})
+	}
+	sort.Slice(completions, func(a, b int) bool {
+		return completions[a].station < completions[b].station
+	})
+	return completions
+}
+
+// Returns the chance every station of the solution finishes within its cycle
+// time in the same cycle
+func (sol *PartialSolution) GetLineCompletion() float64 {
+	probability := 1.0
+	for _, sc := range sol.GetStationCompletions() {
+		probability *= sc.probability
+	}
+	return probability
+}
+
+func PrettyStationCompletionsStr(sol *PartialSolution) string {
+	str := ""
+	for _, sc := range sol.GetStationCompletions() {
+		str += "Station " + strconv.Itoa(int(sc.station)) + ":      "
+		str += "Mean " + strconv.FormatFloat(sc.mean, 'f', 2, 64)
+		str += "   SD " + strconv.FormatFloat(sc.sd, 'f', 2, 64)
+		str += "   Completion " + strconv.FormatFloat(sc.probability*100.0, 'f', 1, 64) + "%\n"
+	}
+	return str
+}
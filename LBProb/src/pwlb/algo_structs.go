@@ -1,6 +1,7 @@
 package pwlb
 
 import (
+	"errors"
 	"strconv"
 	"strings"
 )
@@ -13,6 +14,11 @@ type Task struct {
 	cost     float64    // time in seconds to complete task
 	prereqs  []TaskId_t // other tasks that must be completed prior this one being started
 	assigned bool
+	side     TaskSide      // side of a two-sided line the task is done on
+	pin      *StationRange // stations the task has to go to, nil for any
+	sd       float64       // standard deviation of the task time, cost is the mean
+	dist     TaskTimeDist  // distribution of the task time when it varies
+	equip    []string      // names of the equipment the task needs at its station
 }
 
 type Workstation struct {
@@ -24,14 +30,72 @@ func NewTask(_id TaskId_t, _c float64) *Task {
 	return &Task{id: _id, cost: _c}
 }
 
-// Returns a task as a string like "2,20.2,nil" or "4,3.3,2 3"
+// Returns a task as a string like "2,20.2,nil" or "4,3.3,2 3", followed by any
+// attributes that aren't at their default like "4,3.3,2 3,side=L"
 func (t *Task) ToStr() string {
 	str := strconv.Itoa(int(t.id))
-	str += "," + strconv.FormatFloat(float64(t.cost), 'f', 1, 64) + ","
+	str += "," + costToStr(t.cost) + ","
 	str += taskIdsToStr(t.prereqs)
+	str += t.attrsToStr()
 	return strings.TrimSpace(str)
 }
 
+// Returns the attributes of a task that aren't at their default, each as a spec
+// field like ",side=L"
+func (t *Task) attrsToStr() string {
+	str := ""
+	if t.side != SideEither {
+		str += ",side=" + t.side.ToStr()
+	}
+	if t.pin != nil {
+		str += ",station=" + t.pin.ToStr()
+	}
+	if t.sd != 0.0 {
+		str += ",sd=" + strconv.FormatFloat(t.sd, 'f', -1, 64)
+	}
+	if t.dist != TimeNormal {
+		str += ",dist=" + t.dist.ToStr()
+	}
+	if len(t.equip) != 0 {
+		str += ",equip=" + strings.Join(t.equip, " ")
+	}
+	return str
+}
+
+// Sets an attribute of a task from a spec field like "side=L", "station=2-4" or
+// "equip=press welder"
+func (t *Task) setAttr(field string) error {
+	kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+	if len(kv) != 2 {
+		return errors.New("Improper task attribute " + field)
+	}
+
+	var err error
+	switch kv[0] {
+	case "side":
+		t.side, err = ParseTaskSide(kv[1])
+	case "station":
+		var pin StationRange
+		pin, err = ParseStationRange(kv[1])
+		t.pin = &pin
+	case "sd":
+		t.sd, err = strconv.ParseFloat(kv[1], 64)
+		if err != nil || t.sd < 0.0 {
+			err = errors.New("Task time SD must be a number no less than zero")
+		}
+	case "dist":
+		t.dist, err = ParseTaskTimeDist(kv[1])
+	case "equip":
+		t.equip = strings.Fields(kv[1])
+		if len(t.equip) == 0 {
+			err = errors.New("Task equipment needs at least one name")
+		}
+	default:
+		err = errors.New("Unknown task attribute " + kv[0])
+	}
+	return err
+}
+
 func NewWorkstation(_id WorkstationId_t) *Workstation {
 	return &Workstation{id: _id}
 }
@@ -63,53 +127,3 @@ func (ws *Workstation) ToStr() string {
 	str += taskIdsToStr(ws.tasks)
 	return strings.TrimSpace(str)
 }
-
-///////////////////////////////////
-// Extra for alternate heuristic
-///////////////////////////////////
-
-type PostReqNode struct {
-	task_id  TaskId_t
-	branches []*PostReqNode
-}
-
-// Represents a graph of follow on dependencies, a DAG
-type PostReqGraph []*PostReqNode
-
-func (node *PostReqNode) IsLeaf() bool {
-	return len(node.branches) == 0
-}
-
-func (node *PostReqNode) HeightWithPath() (int, []TaskId_t) {
-	this_path := []TaskId_t{node.task_id}
-	if node.IsLeaf() {
-		return 0, this_path
-	}
-
-	max_branch_height := 0
-	max_branch_path := []TaskId_t{node.branches[0].task_id}
-	for i, _ := range node.branches {
-		branch_height, branch_path := node.branches[i].HeightWithPath()
-		if branch_height > max_branch_height {
-			max_branch_height = branch_height
-			max_branch_path = branch_path
-		}
-	}
-
-	return (1 + max_branch_height), (append(this_path, max_branch_path...))
-}
-
-func (node *PostReqNode) contains(query TaskId_t) bool {
-	if node.task_id == query {
-		return true
-	}
-
-	for _, subnode := range node.branches {
-		if subnode.contains(query) {
-			return true
-		}
-	}
-	return false
-}
-
-//func (g *PostReqGraph) MaxHeight()
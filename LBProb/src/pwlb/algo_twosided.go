@@ -0,0 +1,313 @@
+package pwlb
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Side of a two-sided line a task has to be done on
+type TaskSide int
+
+const (
+	SideEither TaskSide = iota // either side will do
+	SideLeft
+	SideRight
+)
+
+var task_side_names = map[string]TaskSide{
+	"E": SideEither,
+	"L": SideLeft,
+	"R": SideRight,
+}
+
+func ParseTaskSide(name string) (TaskSide, error) {
+	side, ok := task_side_names[name]
+	if !ok {
+		return SideEither, errors.New("Unknown task side " + name + ", expected L, R or E")
+	}
+	return side, nil
+}
+
+func (side TaskSide) ToStr() string {
+	for name, named_side := range task_side_names {
+		if named_side == side {
+			return name
+		}
+	}
+	return "?"
+}
+
+// Returns the side of a two-sided station the task is done on, SideEither when
+// the solution isn't for a two-sided line
+func (sol *PartialSolution) GetSide(id TaskId_t) TaskSide {
+	return sol.sides[id]
+}
+
+// Returns the time within the cycle a task starts at on a two-sided line or a
+// multi-manned station
+func (sol *PartialSolution) GetStart(id TaskId_t) float64 {
+	return sol.starts[id]
+}
+
+// Returns the fewest stations a two-sided line can have. Both sides of a station
+// share the total task time and the tasks tied to a side must fit that side.
+func (tc *TaskContainer) twoSidedLowerBound() int {
+	var side_time [3]float64
+	task_time_sum := 0.0
+	for i, _ := range tc.tasks {
+		side_time[tc.tasks[i].side] += tc.tasks[i].cost
+		task_time_sum += tc.tasks[i].cost
+	}
+
+	lower_bound := int(math.Ceil(task_time_sum/(2.0*tc.cycleTime) - k_time_epsilon))
+	for _, side := range []TaskSide{SideLeft, SideRight} {
+		if lb := tc.stationsNeeded(side_time[side]); lb > lower_bound {
+			lower_bound = lb
+		}
+	}
+	return lower_bound
+}
+
+///////////////////////////////////
+// Two-sided solve
+///////////////////////////////////
+
+// Fills two-sided stations one at a time. Each step takes the first available
+// task by the priority rule that can still finish within the cycle time on a side
+// it may be done on. A task starts when its side is free and every prereq at the
+// same station has finished, so a prereq on the other side can leave its side
+// idle. Of the two sides the one where the task starts first is used, then the
+// one with less work, then the left. Tasks zoned apart don't share a station
+// even on different sides, and pins are kept as in solveStationOriented.
+func solveTwoSided(ctx context.Context, tc *TaskContainer, opts *SolveOptions,
+	less func(lhs, rhs int) bool) *SolveResult {
+	sol := &PartialSolution{tc: tc, sides: make(map[TaskId_t]TaskSide),
+		starts: make(map[TaskId_t]float64)}
+	num_tasks := len(tc.tasks)
+	if num_tasks == 0 {
+		return newCompletedResult(sol)
+	}
+	graph := tc.GetGraph()
+	if opts.getServiceZ() != 0.0 {
+		panic("Two-sided lines can't be solved to a service level")
+	}
+	if tc.HasSetups() {
+		panic("Two-sided lines can't be solved with setup times")
+	}
+
+	progress := newSolveProgress(opts)
+	progress.bound(tc.GetLowerBound())
+
+	cur_ws_id := WorkstationId_t(tc.tasks[0].id)
+	pins, err := tc.getPinWindows(opts)
+	if err != nil {
+		panic(err.Error())
+	}
+	if pins != nil {
+		less = pins.dueFirst(less, &cur_ws_id)
+	}
+
+	avail := &taskHeap{less: less}
+	indegree := make([]int, num_tasks)
+	for i, _ := range tc.tasks {
+		indegree[i] = len(graph.prereqs[i])
+		if indegree[i] == 0 {
+			avail.idxs = append(avail.idxs, i)
+		}
+	}
+	heap.Init(avail)
+
+	// Time each side of the open station is busy until and the finish time of the
+	// tasks assigned to it
+	var side_end [3]float64
+	finish := make([]float64, num_tasks)
+	at_station := make([]bool, num_tasks)
+	var station_idxs []int
+	apart := tc.apartIdxs()
+
+	if ctx.Err() != nil {
+		return &SolveResult{sol, SolveInterrupted}
+	}
+
+	var skipped []int
+	sol.assignments = make([]TaskAssignment, 0, num_tasks)
+	for len(sol.assignments) != num_tasks {
+		placed := false
+		for avail.Len() != 0 {
+			idx := heap.Pop(avail).(int)
+			side, start, ok := placeTwoSided(tc, graph, idx, &side_end, finish, at_station)
+			ok = ok && pins.allows(idx, cur_ws_id)
+			if apart != nil {
+				for _, other := range apart[idx] {
+					ok = ok && !at_station[other]
+				}
+			}
+			if !ok {
+				skipped = append(skipped, idx)
+				continue
+			}
+
+			id := tc.tasks[idx].id
+			sol.assignments = append(sol.assignments, TaskAssignment{id, cur_ws_id})
+			sol.sides[id] = side
+			sol.starts[id] = start
+			finish[idx] = start + tc.tasks[idx].cost
+			side_end[side] = finish[idx]
+			at_station[idx] = true
+			station_idxs = append(station_idxs, idx)
+
+			for _, post_idx := range graph.postreqs[idx] {
+				indegree[post_idx]--
+				if indegree[post_idx] == 0 {
+					heap.Push(avail, post_idx)
+				}
+			}
+			placed = true
+			break
+		}
+		if placed {
+			continue
+		}
+
+		for _, idx := range skipped {
+			heap.Push(avail, idx)
+		}
+		skipped = skipped[:0]
+
+		if len(station_idxs) == 0 {
+			pins.checkEmpty(tc, avail, cur_ws_id)
+			panic("Task " + tc.tasks[avail.idxs[0]].ToStr() + " exceeds the cycle time")
+		}
+		pins.checkClosed(tc, avail, cur_ws_id)
+
+		progress.iteration()
+		if ctx.Err() != nil {
+			return &SolveResult{sol, SolveInterrupted}
+		}
+
+		cur_ws_id++
+		side_end = [3]float64{}
+		for _, idx := range station_idxs {
+			at_station[idx] = false
+		}
+		station_idxs = station_idxs[:0]
+		if pins != nil {
+			heap.Init(avail)
+		}
+	}
+
+	progress.iteration()
+	progress.incumbent(sol)
+	return newCompletedResult(sol)
+}
+
+// Returns the side and start time a task would get at the open station, or false
+// if it can't finish within the cycle time on any side it may be done on
+func placeTwoSided(tc *TaskContainer, graph *TaskGraph, idx int, side_end *[3]float64,
+	finish []float64, at_station []bool) (TaskSide, float64, bool) {
+	ready := 0.0
+	for _, prereq_idx := range graph.prereqs[idx] {
+		if at_station[prereq_idx] && finish[prereq_idx] > ready {
+			ready = finish[prereq_idx]
+		}
+	}
+
+	sides := []TaskSide{SideLeft, SideRight}
+	if tc.tasks[idx].side != SideEither {
+		sides = []TaskSide{tc.tasks[idx].side}
+	}
+
+	best_side, best_start, found := SideEither, 0.0, false
+	for _, side := range sides {
+		start := math.Max(side_end[side], ready)
+		if start+tc.tasks[idx].cost > tc.cycleTime+k_time_epsilon {
+			continue
+		}
+		if !found || start < best_start || (start == best_start && side_end[side] < side_end[best_side]) {
+			best_side, best_start, found = side, start, true
+		}
+	}
+	return best_side, best_start, found
+}
+
+// Checks the sides and start times of a complete two-sided solution. Every task
+// must be on a side it may be done on, tasks on a side must not overlap or run
+// past the cycle time and a task may only start after its prereqs at the same
+// station have finished.
+func (sol *PartialSolution) checkTwoSided(tc *TaskContainer, station_of map[TaskId_t]WorkstationId_t) error {
+	by_side := make(map[operatorKey][]scheduledTask)
+
+	for taskid, wsid := range station_of {
+		_task := &tc.tasks[tc.taskMapping[taskid]]
+		side, ok := sol.sides[taskid]
+		if !ok || side == SideEither {
+			return errors.New("Task " + _task.ToStr() + " has no side")
+		}
+		if _task.side != SideEither && _task.side != side {
+			return errors.New("Task " + _task.ToStr() + " on the wrong side")
+		}
+
+		scheduled, err := sol.scheduleTask(tc, station_of, _task)
+		if err != nil {
+			return err
+		}
+		key := operatorKey{ws: wsid, side: side}
+		by_side[key] = append(by_side[key], scheduled)
+	}
+
+	return checkOverlaps(by_side)
+}
+
+// A task with the time it starts and ends within the cycle
+type scheduledTask struct {
+	start, end float64
+	id         TaskId_t
+}
+
+// Returns when a task runs at its station, or an error when it runs outside the
+// cycle, a prereq is at a later station or it starts before a prereq at the same
+// station has finished
+func (sol *PartialSolution) scheduleTask(tc *TaskContainer, station_of map[TaskId_t]WorkstationId_t,
+	_task *Task) (scheduledTask, error) {
+	wsid := station_of[_task.id]
+	start := sol.starts[_task.id]
+	end := start + _task.cost
+	if start < -k_time_epsilon || end > tc.cycleTime+k_time_epsilon {
+		return scheduledTask{}, errors.New("Task " + _task.ToStr() + " runs outside the cycle on workstation " +
+			strconv.Itoa(int(wsid)))
+	}
+
+	for _, prereqid := range _task.prereqs {
+		prereq_ws_id, found_prereq := station_of[prereqid]
+		if !found_prereq || prereq_ws_id > wsid {
+			return scheduledTask{}, errors.New("Not all prereqs found for task " + _task.ToStr())
+		}
+		prereq_end := sol.starts[prereqid] + tc.tasks[tc.taskMapping[prereqid]].cost
+		if prereq_ws_id == wsid && prereq_end > start+k_time_epsilon {
+			return scheduledTask{}, errors.New("Task " + _task.ToStr() + " starts before its prereq " +
+				strconv.Itoa(int(prereqid)) + " finishes")
+		}
+	}
+	return scheduledTask{start, end, _task.id}, nil
+}
+
+// Returns an error for the first two tasks of an operator that overlap
+func checkOverlaps(by_operator map[operatorKey][]scheduledTask) error {
+	for key, tasks := range by_operator {
+		sort.Slice(tasks, func(a, b int) bool {
+			return tasks[a].start < tasks[b].start
+		})
+		for i := 1; i < len(tasks); i++ {
+			if tasks[i].start < tasks[i-1].end-k_time_epsilon {
+				return errors.New("Tasks " + strconv.Itoa(int(tasks[i-1].id)) + " and " +
+					strconv.Itoa(int(tasks[i].id)) + " overlap on workstation " +
+					strconv.Itoa(int(key.ws)) + " " + key.ToStr())
+			}
+		}
+	}
+	return nil
+}
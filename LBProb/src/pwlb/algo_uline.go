@@ -0,0 +1,62 @@
+package pwlb
+
+import "errors"
+
+// Shape of the line the stations are laid out along
+type LineLayout int
+
+const (
+	LayoutStraight LineLayout = iota // a task can start once all its prereqs are done
+	LayoutU                          // a task can start once all its prereqs or all its postreqs are done
+	LayoutTwoSided                   // each station has a left and right side worked at the same time
+)
+
+var line_layout_names = map[string]LineLayout{
+	"straight":  LayoutStraight,
+	"u":         LayoutU,
+	"two-sided": LayoutTwoSided,
+}
+
+func ParseLineLayout(name string) (LineLayout, error) {
+	layout, ok := line_layout_names[name]
+	if !ok {
+		return LayoutStraight, errors.New("Unknown line layout " + name + ", expected straight, u or two-sided")
+	}
+	return layout, nil
+}
+
+// On a U-line the product passes every station on the way out, the front side,
+// then again in reverse on the way back, the back side. Returns true if the task
+// is done on the back side of its station.
+func (sol *PartialSolution) IsOnBack(id TaskId_t) bool {
+	return sol.back[id]
+}
+
+// Returns the place of a station side along the path of the product, where
+// ws_end_id is one past the last station. All front sides come before all back
+// sides, which are passed in reverse order.
+func linePosition(wsid WorkstationId_t, back bool, ws_end_id WorkstationId_t) int {
+	if !back {
+		return int(wsid)
+	}
+	return int(2*ws_end_id - 1 - wsid)
+}
+
+// Returns an error if the task is done on a side the layout doesn't have or
+// before one of its prereqs along the path of the product
+func (sol *PartialSolution) checkPrecedence(tc *TaskContainer, station_of map[TaskId_t]WorkstationId_t,
+	ws_end_id WorkstationId_t, _task *Task) error {
+	wsid := station_of[_task.id]
+	if sol.back[_task.id] && tc.layout != LayoutU {
+		return errors.New("Task " + _task.ToStr() + " on the back side of a straight line")
+	}
+
+	task_pos := linePosition(wsid, sol.back[_task.id], ws_end_id)
+	for _, prereqid := range _task.prereqs {
+		prereq_ws_id, found_prereq := station_of[prereqid]
+		if !found_prereq || linePosition(prereq_ws_id, sol.back[prereqid], ws_end_id) > task_pos {
+			return errors.New("Not all prereqs found for task " + _task.ToStr())
+		}
+	}
+	return nil
+}
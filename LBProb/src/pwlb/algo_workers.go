@@ -0,0 +1,396 @@
+package pwlb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Most cycle times tried when assigning a crew
+const k_crew_max_tries = 60
+
+// A worker of a crew, who may be faster or slower than the task time on some
+// tasks and unable to do others, like a worker with a disability
+type Worker struct {
+	name  string
+	times map[TaskId_t]float64 // time on the tasks where it differs from the task time, +Inf for tasks they can't do
+}
+
+func (w *Worker) GetName() string {
+	return w.name
+}
+
+// Returns true if the worker can do the task
+func (w *Worker) CanDo(id TaskId_t) bool {
+	return !math.IsInf(w.times[id], 1)
+}
+
+// Returns a worker as a spec line like "worker,Ann,2=x 3=14.5", x marking the
+// tasks they can't do
+func (w *Worker) ToStr() string {
+	var ids []int
+	for id, _ := range w.times {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	var times []string
+	for _, id := range ids {
+		time_str := "x"
+		if time := w.times[TaskId_t(id)]; !math.IsInf(time, 1) {
+			time_str = costToStr(time)
+		}
+		times = append(times, strconv.Itoa(id)+"="+time_str)
+	}
+	if len(times) == 0 {
+		return "worker," + w.name
+	}
+	return "worker," + w.name + "," + strings.Join(times, " ")
+}
+
+func (tc *TaskContainer) GetWorkers() []Worker {
+	return tc.workers
+}
+
+// Returns the time of a worker on a task, both by container index, +Inf if they
+// can't do it
+func (tc *TaskContainer) workerTime(worker, idx int) float64 {
+	if time, ok := tc.workers[worker].times[tc.tasks[idx].id]; ok {
+		return time
+	}
+	return tc.tasks[idx].cost
+}
+
+// Parses a line like "worker,Ann,2=x 3=14.5" naming a worker and their times on
+// the tasks where they differ from the task time, x for a task they can't do
+func (tc *TaskContainer) parseWorker(fields []string) error {
+	if len(fields) < 2 || len(fields) > 3 || strings.TrimSpace(fields[1]) == "" {
+		return errors.New("Improper worker format")
+	}
+	worker := Worker{name: strings.TrimSpace(fields[1]), times: make(map[TaskId_t]float64)}
+	for i, _ := range tc.workers {
+		if tc.workers[i].name == worker.name {
+			return errors.New("Worker " + worker.name + " listed twice")
+		}
+	}
+
+	if len(fields) == 3 {
+		for _, field := range strings.Fields(fields[2]) {
+			kv := strings.SplitN(field, "=", 2)
+			id, err := strconv.Atoi(kv[0])
+			if err != nil || len(kv) != 2 {
+				return errors.New("Improper worker time " + field)
+			}
+			time := math.Inf(1)
+			if kv[1] != "x" {
+				if time, err = strconv.ParseFloat(kv[1], 64); err != nil || time < 0.0 {
+					return errors.New("Worker time must be a number no less than zero or x in " + field)
+				}
+			}
+			worker.times[TaskId_t(id)] = time
+		}
+	}
+
+	tc.workers = append(tc.workers, worker)
+	return nil
+}
+
+// Stops when a worker has a time on a task that isn't in the spec
+func (tc *TaskContainer) checkWorkerTasks() {
+	for i, _ := range tc.workers {
+		for id, _ := range tc.workers[i].times {
+			if _, ok := tc.taskMapping[id]; !ok {
+				log.Fatal("Worker " + tc.workers[i].name + " has a time on unknown task " + strconv.Itoa(int(id)))
+			}
+		}
+	}
+}
+
+// Returns the name of the worker at the station, empty unless a crew was assigned
+func (sol *PartialSolution) GetWorker(wsid WorkstationId_t) string {
+	worker, ok := sol.workers[wsid]
+	if !ok {
+		return ""
+	}
+	return sol.getTaskContainer().workers[worker].name
+}
+
+// Returns the time of a task by container index at the station, the time of the
+// worker there when a crew was assigned
+func (sol *PartialSolution) taskTime(tc *TaskContainer, idx int, wsid WorkstationId_t) float64 {
+	if worker, ok := sol.workers[wsid]; ok {
+		return tc.workerTime(worker, idx)
+	}
+	return tc.tasks[idx].cost
+}
+
+// Returns an error for a station without a worker, a worker at two stations or a
+// task the worker at its station can't do
+func (sol *PartialSolution) checkWorkers(tc *TaskContainer) error {
+	station_of := make(map[int]WorkstationId_t)
+	for wsid, worker := range sol.workers {
+		if other, ok := station_of[worker]; ok {
+			return errors.New("Worker " + tc.workers[worker].name + " is at workstations " +
+				strconv.Itoa(int(other)) + " and " + strconv.Itoa(int(wsid)))
+		}
+		station_of[worker] = wsid
+	}
+	for _, asg := range sol.assignments {
+		worker, ok := sol.workers[asg.j]
+		if !ok {
+			return errors.New("Workstation " + strconv.Itoa(int(asg.j)) + " has no worker")
+		}
+		if !tc.workers[worker].CanDo(asg.i) {
+			return errors.New("Worker " + tc.workers[worker].name + " can't do task " +
+				strconv.Itoa(int(asg.i)) + " at workstation " + strconv.Itoa(int(asg.j)))
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////
+// Crew assignment
+///////////////////////////////////
+
+// Outcome of assigning a crew, see AssignCrew
+type CrewResult struct {
+	sol       *PartialSolution
+	cycleTime float64 // longest station time of the solution
+	status    SolveStatus
+}
+
+func (cr *CrewResult) GetSolution() *PartialSolution {
+	return cr.sol
+}
+
+func (cr *CrewResult) GetCycleTime() float64 {
+	return cr.cycleTime
+}
+
+func (cr *CrewResult) GetStatus() SolveStatus {
+	return cr.status
+}
+
+// Assigns the workers of the container to stations, one each, and the tasks to
+// the stations, looking for the shortest cycle time the crew can work at. Task
+// times are those of the worker at the station. Stations are filled one at a time
+// at a trial cycle time: each free worker fills the station by positional weight
+// on the fastest times of the tasks, and the worker who takes the most work, by
+// those times, gets it, or of those taking as much the one least suited to the
+// tasks left. A trial succeeds when every task is assigned before the
+// workers run out, and the cycle time is found by bisection between a lower
+// bound and the longest station of the last success. Like the other heuristics
+// it may miss a shorter cycle time. Workers may be left without a station when
+// the others cover every task.
+//
+// The metrics of the solution use the cycle time of the container, set it to the
+// one found to report them at it. If the context ends the best solution so far
+// is returned as interrupted. Fails when the container has no workers, isn't a
+// straight line, has zones, pins or setup times, or the crew can't cover the
+// tasks at any cycle time.
+func AssignCrew(ctx context.Context, tc *TaskContainer) (*CrewResult, error) {
+	num_workers := len(tc.workers)
+	if num_workers == 0 {
+		return nil, errors.New("The spec has no workers")
+	}
+	if tc.layout != LayoutStraight {
+		return nil, errors.New("A crew can only be assigned on a straight line")
+	}
+	pinned := false
+	for i, _ := range tc.tasks {
+		pinned = pinned || tc.tasks[i].pin != nil
+	}
+	if len(tc.zones) != 0 || pinned || tc.HasSetups() {
+		return nil, errors.New("A crew can't be assigned with zones, pins or setup times")
+	}
+	if len(tc.tasks) == 0 {
+		return &CrewResult{&PartialSolution{tc: tc}, 0.0, SolveCompleted}, nil
+	}
+
+	// Fastest time of any worker on each task, the cycle time can't be below the
+	// longest of them or the mean load at those times
+	cs := &crewSearch{tc: tc, graph: tc.GetGraph(), minTimes: make([]float64, len(tc.tasks))}
+	total, longest, slowest_total := 0.0, 0.0, 0.0
+	for i, _ := range tc.tasks {
+		cs.minTimes[i] = math.Inf(1)
+		slowest := 0.0
+		for worker, _ := range tc.workers {
+			if time := tc.workerTime(worker, i); !math.IsInf(time, 1) {
+				cs.minTimes[i] = math.Min(cs.minTimes[i], time)
+				slowest = math.Max(slowest, time)
+			}
+		}
+		if math.IsInf(cs.minTimes[i], 1) {
+			return nil, errors.New("No worker can do task " + strconv.Itoa(int(tc.tasks[i].id)))
+		}
+		total += cs.minTimes[i]
+		longest = math.Max(longest, cs.minTimes[i])
+		slowest_total += slowest
+	}
+	cs.graph.buildClosure()
+	cs.weights = make([]float64, len(tc.tasks))
+	for i, _ := range tc.tasks {
+		cs.weights[i] = cs.minTimes[i]
+		cs.graph.followers[i].forEach(func(other int) {
+			cs.weights[i] += cs.minTimes[other]
+		})
+	}
+
+	best := cs.try(slowest_total)
+	if best == nil {
+		return nil, errors.New("The crew of " + strconv.Itoa(num_workers) +
+			" can't cover the tasks at any cycle time")
+	}
+	lo, hi := math.Max(longest, total/float64(num_workers)), cs.longestStation(best)
+	for tries := 0; tries < k_crew_max_tries && hi-lo > k_time_epsilon; tries++ {
+		if ctx.Err() != nil {
+			return &CrewResult{best, hi, SolveInterrupted}, nil
+		}
+		mid := (lo + hi) / 2.0
+		if sol := cs.try(mid); sol != nil {
+			best, hi = sol, cs.longestStation(sol)
+		} else {
+			lo = mid
+		}
+	}
+	return &CrewResult{best, hi, SolveCompleted}, nil
+}
+
+// State of AssignCrew, fixed over the trials
+type crewSearch struct {
+	tc       *TaskContainer
+	graph    *TaskGraph
+	minTimes []float64 // fastest time of any worker on each task
+	weights  []float64 // positional weight of each task by the fastest times
+}
+
+// Returns a solution at the cycle time, nil if the heuristic finds none
+func (cs *crewSearch) try(cycle_time float64) *PartialSolution {
+	tc := cs.tc
+	num_tasks := len(tc.tasks)
+	sol := &PartialSolution{tc: tc, workers: make(map[WorkstationId_t]int)}
+	indegree := make([]int, num_tasks)
+	for i, _ := range tc.tasks {
+		indegree[i] = len(cs.graph.prereqs[i])
+	}
+	used := make([]bool, len(tc.workers))
+
+	wsid := WorkstationId_t(tc.tasks[0].id)
+	for len(sol.assignments) != num_tasks {
+		best_worker, best_work, best_unsuited := -1, 0.0, 0.0
+		var best_idxs []int
+		for worker, _ := range tc.workers {
+			if used[worker] {
+				continue
+			}
+			idxs, work := cs.fillStation(worker, cycle_time, indegree)
+			if len(idxs) == 0 {
+				continue
+			}
+			// Of workers taking as much work the one least suited to the tasks left
+			// goes first, keeping the others for those tasks
+			unsuited := cs.unsuitedTime(worker, cycle_time, indegree, idxs)
+			if best_worker == -1 || work > best_work+k_time_epsilon ||
+				(work > best_work-k_time_epsilon && unsuited > best_unsuited+k_time_epsilon) {
+				best_worker, best_work, best_unsuited, best_idxs = worker, work, unsuited, idxs
+			}
+		}
+		if best_worker == -1 {
+			return nil
+		}
+
+		used[best_worker] = true
+		sol.workers[wsid] = best_worker
+		for _, idx := range best_idxs {
+			sol.assignments = append(sol.assignments, TaskAssignment{tc.tasks[idx].id, wsid})
+			indegree[idx] = -1
+			for _, post_idx := range cs.graph.postreqs[idx] {
+				indegree[post_idx]--
+			}
+		}
+		wsid++
+	}
+	return sol
+}
+
+// Returns the tasks by container index the worker would take at a station of
+// the cycle time, in the order they take them, with their fastest times summed.
+// Tasks with no unassigned prereqs, marked by an indegree of zero, are
+// available, assigned ones have -1.
+func (cs *crewSearch) fillStation(worker int, cycle_time float64, indegree []int) ([]int, float64) {
+	tc := cs.tc
+	local := make([]int, len(indegree))
+	copy(local, indegree)
+
+	var avail, idxs []int
+	for i, _ := range local {
+		if local[i] == 0 {
+			avail = append(avail, i)
+		}
+	}
+
+	work, load := 0.0, 0.0
+	for {
+		pick := -1
+		for pos, idx := range avail {
+			time := tc.workerTime(worker, idx)
+			if math.IsInf(time, 1) || load+time > cycle_time+k_time_epsilon {
+				continue
+			}
+			if pick == -1 || cs.weights[idx] > cs.weights[avail[pick]] ||
+				(cs.weights[idx] == cs.weights[avail[pick]] && idx < avail[pick]) {
+				pick = pos
+			}
+		}
+		if pick == -1 {
+			return idxs, work
+		}
+
+		idx := avail[pick]
+		avail = append(avail[:pick], avail[pick+1:]...)
+		idxs = append(idxs, idx)
+		work += cs.minTimes[idx]
+		load += tc.workerTime(worker, idx)
+		for _, post_idx := range cs.graph.postreqs[idx] {
+			local[post_idx]--
+			if local[post_idx] == 0 {
+				avail = append(avail, post_idx)
+			}
+		}
+	}
+}
+
+// Returns how much longer than the fastest worker the worker takes on the tasks
+// left unassigned after the given ones, a task they can't do counting as the
+// cycle time
+func (cs *crewSearch) unsuitedTime(worker int, cycle_time float64, indegree []int, taken []int) float64 {
+	is_taken := make(map[int]bool)
+	for _, idx := range taken {
+		is_taken[idx] = true
+	}
+	unsuited := 0.0
+	for i, _ := range indegree {
+		if indegree[i] == -1 || is_taken[i] {
+			continue
+		}
+		if time := cs.tc.workerTime(worker, i); math.IsInf(time, 1) {
+			unsuited += cycle_time
+		} else {
+			unsuited += time - cs.minTimes[i]
+		}
+	}
+	return unsuited
+}
+
+// Returns the time of the busiest station of the solution
+func (cs *crewSearch) longestStation(sol *PartialSolution) float64 {
+	longest := 0.0
+	for _, load := range sol.getOperatorLoads() {
+		longest = math.Max(longest, load)
+	}
+	return longest
+}
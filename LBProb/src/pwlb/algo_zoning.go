@@ -0,0 +1,439 @@
+package pwlb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kind of a zoning constraint
+type ZoneKind int
+
+const (
+	ZoneTogether ZoneKind = iota // the tasks must share a station, like tasks using one fixture
+	ZoneApart                    // no two of the tasks may share a station, like welding and painting
+)
+
+var zone_kind_names = map[string]ZoneKind{
+	"together": ZoneTogether,
+	"apart":    ZoneApart,
+}
+
+func (kind ZoneKind) ToStr() string {
+	for name, named_kind := range zone_kind_names {
+		if named_kind == kind {
+			return name
+		}
+	}
+	return "?"
+}
+
+// A zoning constraint on where a group of tasks may be assigned
+type Zone struct {
+	kind  ZoneKind
+	tasks []TaskId_t
+}
+
+func (zone *Zone) GetKind() ZoneKind {
+	return zone.kind
+}
+
+func (zone *Zone) GetTasks() []TaskId_t {
+	return zone.tasks
+}
+
+// Returns a zone as a spec line like "together,1 2 3"
+func (zone *Zone) ToStr() string {
+	var ids []string
+	for _, id := range zone.tasks {
+		ids = append(ids, strconv.Itoa(int(id)))
+	}
+	return zone.kind.ToStr() + "," + strings.Join(ids, " ")
+}
+
+func (tc *TaskContainer) GetZones() []Zone {
+	return tc.zones
+}
+
+// Parses a line like "together,1 2 3" or "apart,4 5" listing the tasks of a zone
+func (tc *TaskContainer) parseZone(fields []string) error {
+	if len(fields) != 2 {
+		return errors.New("Improper zone format")
+	}
+
+	zone := Zone{kind: zone_kind_names[fields[0]]}
+	for _, idstr := range strings.Fields(fields[1]) {
+		id, err := strconv.Atoi(idstr)
+		if err != nil {
+			return errors.New("Improper task id " + idstr + " in zone")
+		}
+		zone.tasks = append(zone.tasks, TaskId_t(id))
+	}
+	if len(zone.tasks) < 2 {
+		return errors.New("A zone needs at least two tasks")
+	}
+
+	tc.zones = append(tc.zones, zone)
+	return nil
+}
+
+// Stops on a zone naming a task that isn't in the container
+func (tc *TaskContainer) checkZoneTasks() {
+	for i, _ := range tc.zones {
+		for _, id := range tc.zones[i].tasks {
+			if _, ok := tc.taskMapping[id]; !ok {
+				log.Fatal("Zone " + tc.zones[i].ToStr() + " has unknown task " + strconv.Itoa(int(id)))
+			}
+		}
+	}
+}
+
+// Returns true if some zone keeps tasks together
+func (tc *TaskContainer) hasTogetherZones() bool {
+	for i, _ := range tc.zones {
+		if tc.zones[i].kind == ZoneTogether {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns for each task the indices of the tasks it may not share a station with,
+// or nil when no zone keeps tasks apart
+func (tc *TaskContainer) apartIdxs() [][]int {
+	var apart [][]int
+	for i, _ := range tc.zones {
+		if tc.zones[i].kind != ZoneApart {
+			continue
+		}
+		if apart == nil {
+			apart = make([][]int, len(tc.tasks))
+		}
+		for _, id := range tc.zones[i].tasks {
+			idx := tc.taskMapping[id]
+			for _, other_id := range tc.zones[i].tasks {
+				if other_idx := tc.taskMapping[other_id]; other_idx != idx {
+					apart[idx] = append(apart[idx], other_idx)
+				}
+			}
+		}
+	}
+	return apart
+}
+
+///////////////////////////////////
+// Zoned solve
+///////////////////////////////////
+
+// Solves a container with tasks zoned together. Each group of tasks that must
+// share a station is merged into one task with their total time, the prereqs of
+// all of them, the stations all their pins allow and the id of the first of
+// them, and the solver works on the
+// merged tasks. The solution is then given back in the tasks of the container,
+// the tasks of a group in topological order at the station of the merged task.
+// Zones keeping tasks apart are passed on for the solver to keep.
+func solveZoned(ctx context.Context, tc *TaskContainer, opts *SolveOptions, solver SolverFunc) *SolveResult {
+	merged, groups, err := tc.mergeTogetherZones(opts)
+	if err != nil {
+		panic(err.Error())
+	}
+	result := solver(ctx, merged, opts)
+	return &SolveResult{tc.splitMergedSolution(result.sol, groups), result.status}
+}
+
+// Returns the container with the tasks zoned together merged and the tasks of
+// each merged task by its id, in topological order. Fails if a zone can't be met
+// by any solution, or the tasks have setup times, which don't carry over to the
+// merged tasks.
+func (tc *TaskContainer) mergeTogetherZones(opts *SolveOptions) (*TaskContainer, map[TaskId_t][]int, error) {
+	if tc.HasSetups() {
+		return nil, nil, errors.New("Tasks zoned together can't be solved with setup times")
+	}
+	graph := tc.GetGraph()
+	graph.buildClosure()
+
+	// Zones sharing a task are one group, each task points at the first task of
+	// its group by container index
+	group_of := make([]int, len(tc.tasks))
+	for i, _ := range group_of {
+		group_of[i] = i
+	}
+	var find func(idx int) int
+	find = func(idx int) int {
+		if group_of[idx] != idx {
+			group_of[idx] = find(group_of[idx])
+		}
+		return group_of[idx]
+	}
+	for i, _ := range tc.zones {
+		if tc.zones[i].kind != ZoneTogether {
+			continue
+		}
+		first := find(tc.taskMapping[tc.zones[i].tasks[0]])
+		for _, id := range tc.zones[i].tasks[1:] {
+			other := find(tc.taskMapping[id])
+			if other < first {
+				first, other = other, first
+			}
+			group_of[other] = first
+		}
+	}
+
+	topo_pos := make([]int, len(tc.tasks))
+	for pos, idx := range graph.topoOrder {
+		topo_pos[idx] = pos
+	}
+	members := make(map[int][]int)
+	for i, _ := range tc.tasks {
+		members[find(i)] = append(members[find(i)], i)
+	}
+	for _, idxs := range members {
+		sort.Slice(idxs, func(a, b int) bool {
+			return topo_pos[idxs[a]] < topo_pos[idxs[b]]
+		})
+	}
+
+	merged := NewTaskContainer()
+	merged.cycleTime = tc.cycleTime
+	merged.layout = tc.layout
+	groups := make(map[TaskId_t][]int)
+	for i, _ := range tc.tasks {
+		if find(i) != i {
+			continue
+		}
+		idxs := members[i]
+		if err := tc.checkTogether(graph, idxs); err != nil {
+			return nil, nil, err
+		}
+
+		merged_task := NewTask(tc.tasks[i].id, 0.0)
+		in_prereqs := make(map[TaskId_t]bool)
+		for _, idx := range idxs {
+			merged_task.cost += tc.tasks[idx].cost
+			merged_task.sd += tc.tasks[idx].sd * tc.tasks[idx].sd
+			if side := tc.tasks[idx].side; side != SideEither {
+				if merged_task.side != SideEither && merged_task.side != side {
+					return nil, nil, errors.New("Tasks " + tc.idxsToStr(idxs) +
+						" zoned together are tied to different sides")
+				}
+				merged_task.side = side
+			}
+			if pin := tc.tasks[idx].pin; pin != nil {
+				if merged_task.pin == nil {
+					merged_task.pin = &StationRange{pin.first, pin.last}
+				}
+				if pin.first > merged_task.pin.first {
+					merged_task.pin.first = pin.first
+				}
+				if pin.last < merged_task.pin.last {
+					merged_task.pin.last = pin.last
+				}
+				if merged_task.pin.first > merged_task.pin.last {
+					return nil, nil, errors.New("Tasks " + tc.idxsToStr(idxs) +
+						" zoned together are pinned to stations that don't overlap")
+				}
+			}
+			for _, prereq_idx := range graph.prereqs[idx] {
+				prereq_id := tc.tasks[find(prereq_idx)].id
+				if find(prereq_idx) != i && !in_prereqs[prereq_id] {
+					in_prereqs[prereq_id] = true
+					merged_task.prereqs = append(merged_task.prereqs, prereq_id)
+				}
+			}
+		}
+		merged_task.sd = math.Sqrt(merged_task.sd)
+		if len(idxs) > 1 && merged_task.cost > tc.cycleTime*float64(opts.getMaxReplicas())+k_time_epsilon {
+			return nil, nil, errors.New("Tasks " + tc.idxsToStr(idxs) + " zoned together exceed the cycle time")
+		}
+
+		groups[merged_task.id] = idxs
+		merged.taskMapping[merged_task.id] = len(merged.tasks)
+		merged.tasks = append(merged.tasks, *merged_task)
+	}
+
+	for i, _ := range tc.zones {
+		if tc.zones[i].kind != ZoneApart {
+			continue
+		}
+		zone := Zone{kind: ZoneApart}
+		for _, id := range tc.zones[i].tasks {
+			merged_id := tc.tasks[find(tc.taskMapping[id])].id
+			for _, other_id := range zone.tasks {
+				if other_id == merged_id {
+					return nil, nil, errors.New("Tasks of zone " + tc.zones[i].ToStr() +
+						" are also zoned together")
+				}
+			}
+			zone.tasks = append(zone.tasks, merged_id)
+		}
+		merged.zones = append(merged.zones, zone)
+	}
+
+	// Groups can still need each other, one through a prereq of its first task and
+	// the other through a prereq of its last
+	merged_graph, err := NewTaskGraph(merged)
+	if err != nil {
+		return nil, nil, errors.New("Zones can't all be met, " + err.Error())
+	}
+	merged.graph = merged_graph
+
+	return merged, groups, nil
+}
+
+// Fails if a task outside a group of tasks zoned together has to come after one
+// of them and before another, so they can't share a station
+func (tc *TaskContainer) checkTogether(graph *TaskGraph, idxs []int) error {
+	in_group := make(map[int]bool)
+	for _, idx := range idxs {
+		in_group[idx] = true
+	}
+	for _, idx := range idxs {
+		for _, other := range idxs {
+			var between error
+			graph.followers[idx].forEach(func(follower int) {
+				if between == nil && !in_group[follower] && graph.predecessors[other].has(follower) {
+					between = errors.New("Task " + tc.tasks[follower].ToStr() + " comes between tasks " +
+						strconv.Itoa(int(tc.tasks[idx].id)) + " and " + strconv.Itoa(int(tc.tasks[other].id)) +
+						" zoned together")
+				}
+			})
+			if between != nil {
+				return between
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the ids of the tasks at the indices separated by spaces
+func (tc *TaskContainer) idxsToStr(idxs []int) string {
+	var ids []string
+	for _, idx := range idxs {
+		ids = append(ids, strconv.Itoa(int(tc.tasks[idx].id)))
+	}
+	return strings.Join(ids, " ")
+}
+
+// Returns the solution of the merged tasks as a solution of the tasks in the
+// container. The tasks of a merged task take its station, side and back flag, on a
+// two-sided line one starting as the one before it finishes.
+func (tc *TaskContainer) splitMergedSolution(merged_sol *PartialSolution, groups map[TaskId_t][]int) *PartialSolution {
+	sol := &PartialSolution{tc: tc, replicas: merged_sol.replicas}
+	if merged_sol.back != nil {
+		sol.back = make(map[TaskId_t]bool)
+	}
+	if merged_sol.sides != nil {
+		sol.sides = make(map[TaskId_t]TaskSide)
+		sol.starts = make(map[TaskId_t]float64)
+	}
+
+	for _, asg := range merged_sol.assignments {
+		start := merged_sol.starts[asg.i]
+		for _, idx := range groups[asg.i] {
+			id := tc.tasks[idx].id
+			sol.assignments = append(sol.assignments, TaskAssignment{id, asg.j})
+			if merged_sol.back[asg.i] {
+				sol.back[id] = true
+			}
+			if sol.sides != nil {
+				sol.sides[id] = merged_sol.sides[asg.i]
+				sol.starts[id] = start
+				start += tc.tasks[idx].cost
+			}
+		}
+	}
+	return sol
+}
+
+///////////////////////////////////
+// Zoning checks and report
+///////////////////////////////////
+
+// Returns the first zone the station assignments break, or nil
+func (tc *TaskContainer) brokenZone(station_of map[TaskId_t]WorkstationId_t) *Zone {
+	for i, _ := range tc.zones {
+		if !tc.zones[i].isMet(station_of) {
+			return &tc.zones[i]
+		}
+	}
+	return nil
+}
+
+// Returns true if the tasks of the zone are at stations the zone allows. A task
+// without a station breaks no zone.
+func (zone *Zone) isMet(station_of map[TaskId_t]WorkstationId_t) bool {
+	seen := make(map[WorkstationId_t]bool)
+	for _, id := range zone.tasks {
+		wsid, ok := station_of[id]
+		if !ok {
+			continue
+		}
+		if zone.kind == ZoneApart && seen[wsid] {
+			return false
+		}
+		seen[wsid] = true
+	}
+	return zone.kind == ZoneApart || len(seen) <= 1
+}
+
+// Returns the zones that are binding, those the solver breaks when solving the
+// same tasks without any zones. A zone that isn't binding is met anyway and
+// costs nothing, one that is binding changes the solution. Fails if the solve
+// without zones doesn't complete.
+func (tc *TaskContainer) GetBindingZones(ctx context.Context, solver SolverFunc, opts *SolveOptions) ([]Zone, error) {
+	if len(tc.zones) == 0 {
+		return nil, nil
+	}
+
+	free := *tc
+	free.zones = nil
+	free.graph = nil
+	result := solver(ctx, &free, opts)
+	if result.status == SolveInterrupted {
+		return nil, errors.New("Solve without zones interrupted")
+	}
+
+	station_of := make(map[TaskId_t]WorkstationId_t)
+	for _, asg := range result.sol.assignments {
+		station_of[asg.i] = asg.j
+	}
+	var binding []Zone
+	for i, _ := range tc.zones {
+		if !tc.zones[i].isMet(station_of) {
+			binding = append(binding, tc.zones[i])
+		}
+	}
+	return binding, nil
+}
+
+// Returns a line for each zone of the container giving the stations of its tasks
+// in the solution, with the binding zones marked binding
+func PrettyZonesStr(sol *PartialSolution, binding []Zone) string {
+	tc := sol.getTaskContainer()
+	station_of := make(map[TaskId_t]WorkstationId_t)
+	for _, asg := range sol.assignments {
+		station_of[asg.i] = asg.j
+	}
+
+	var pretty_str string
+	for i, _ := range tc.zones {
+		zone := &tc.zones[i]
+		var stations []string
+		for _, id := range zone.tasks {
+			stations = append(stations, strconv.Itoa(int(id))+"@"+strconv.Itoa(int(station_of[id])))
+		}
+		pretty_str += "Zone " + zone.ToStr() + ":   " + strings.Join(stations, " ")
+		for j, _ := range binding {
+			if binding[j].ToStr() == zone.ToStr() {
+				pretty_str += "   binding"
+				break
+			}
+		}
+		pretty_str += "\n"
+	}
+	return pretty_str
+}
@@ -1,11 +1,20 @@
 package pwlb
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
 	//"log"
-	//"os"
+	"math/rand"
+	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	//"strings"
 	"testing"
+	"time"
 )
 
 var tc = GetTaskContainer()
@@ -185,17 +194,21 @@ func TestSolutionValidation(t *testing.T) {
 // Testing Extra for alternate heuristic
 ///////////////////////////////////
 
-func TestPostReqNode(t *testing.T) {
+func TestTaskGraph(t *testing.T) {
 	// ##########
 	// Setup a tiny graph to test finding height and longest path
-	node5 := PostReqNode{5, nil}
-	node4 := PostReqNode{4, []*PostReqNode{&node5}}
-	node3 := PostReqNode{3, nil}
-	node2 := PostReqNode{2, []*PostReqNode{&node4}}
-	node1 := PostReqNode{1, []*PostReqNode{&node3}}
-	node0 := PostReqNode{0, []*PostReqNode{&node1, &node2}}
-
-	height, path := node0.HeightWithPath()
+	file_tasks := []string{"0,1.0,nil",
+		"1,1.0,0",
+		"2,1.0,0",
+		"3,9.0,1",
+		"4,1.0,2",
+		"5,1.0,4"}
+	tc.FillFrom(file_tasks)
+	defer tc.Clear()
+
+	graph := tc.GetGraph()
+
+	height, path := graph.HeightWithPath(0)
 	if height != 3 {
 		t.Error("Node height wrong, expected: ", 3, " got: ", height)
 	}
@@ -203,6 +216,42 @@ func TestPostReqNode(t *testing.T) {
 	if !AreTaskIdsSame(path, []TaskId_t{0, 2, 4, 5}) {
 		t.Error("Expected path 0, 2, 4, 5 got: " + taskIdsToStr(path))
 	}
+
+	// By time the short branch through task 3 is longer
+	path_time, path := graph.TimeWithPath(0)
+	if path_time != 11.0 || !AreTaskIdsSame(path, []TaskId_t{0, 1, 3}) {
+		t.Error("Expected path 0, 1, 3 with time 11 got: ", path_time, " "+taskIdsToStr(path))
+	}
+
+	if !AreTaskIdsSame(graph.Followers(2), []TaskId_t{4, 5}) {
+		t.Error("Expected followers 4, 5 got: " + taskIdsToStr(graph.Followers(2)))
+	}
+
+	if !AreTaskIdsSame(graph.Predecessors(5), []TaskId_t{0, 2, 4}) {
+		t.Error("Expected predecessors 0, 2, 4 got: " + taskIdsToStr(graph.Predecessors(5)))
+	}
+
+	if !graph.IsPredecessor(0, 5) || graph.IsPredecessor(1, 5) {
+		t.Error("Expected 0 but not 1 to be a predecessor of 5")
+	}
+
+	if graph.PositionalWeight(1) != 10.0 {
+		t.Error("Expected positional weight 10 got: ", graph.PositionalWeight(1))
+	}
+
+	// ##########
+	// Prereqs listed after the task and cycles
+	tc.Clear()
+	tc.FillFrom([]string{"0,1.0,1", "1,1.0,nil"})
+	if !AreTaskIdsSame(tc.GetGraph().TopologicalOrder(), []TaskId_t{1, 0}) {
+		t.Error("Expected order 1, 0 got: " + taskIdsToStr(tc.GetGraph().TopologicalOrder()))
+	}
+
+	tc.Clear()
+	tc.FillFrom([]string{"0,1.0,1", "1,1.0,0"})
+	if _, err := NewTaskGraph(tc); err == nil {
+		t.Error("Expected a cycle to be reported")
+	}
 }
 
 func TestNextPermutation(t *testing.T) {
@@ -234,3 +283,1466 @@ func TestNextPermutation(t *testing.T) {
 		t.Error("Expected next perm to be: " + taskIdsToStr(expect1) + "got: " + taskIdsToStr(ids1))
 	}
 }
+
+///////////////////////////////////
+// Testing analysis
+///////////////////////////////////
+
+func TestStationIntervals(t *testing.T) {
+	// ##########
+	file_tasks := test_spec1
+	tc.FillFrom(file_tasks)
+	defer tc.Clear()
+
+	// Task 0 needs two stations worth of time with its followers and task 4
+	// needs two stations worth with its predecessors, so both are fixed
+	intervals := tc.GetStationIntervals(2)
+	expected := []string{"0 [0,0]", "1 [0,1]", "2 [0,1]", "3 [0,1]", "4 [1,1]"}
+
+	var got []string
+	for i, _ := range intervals {
+		got = append(got, intervals[i].ToStr())
+	}
+	if !AreStringsSame(got, expected) {
+		t.Error("Expected intervals: ", expected, " got: ", got)
+	}
+
+	if !intervals[0].IsFixed() || intervals[1].IsFixed() {
+		t.Error("Expected only tasks 0 and 4 to be fixed, got: ", got)
+	}
+
+	// With an upper bound below the theoretical min some latest stations
+	// fall before the earliest
+	intervals = tc.GetStationIntervals(1)
+	if intervals[4].Slack() >= 0 {
+		t.Error("Expected negative slack for task 4, got: " + intervals[4].ToStr())
+	}
+}
+
+func TestGraphMetrics(t *testing.T) {
+	// ##########
+	file_tasks := test_spec1
+	tc.FillFrom(file_tasks)
+	defer tc.Clear()
+
+	gm := tc.GetGraphMetrics()
+
+	// Ordered pairs are (0,1) (0,4) (1,4) (3,4) out of 10 pairs
+	if gm.GetOrderStrength() != 0.4 {
+		t.Error("Expected order strength: ", 0.4, " got: ", gm.GetOrderStrength())
+	}
+
+	if gm.GetWestRatio() != 2.5 {
+		t.Error("Expected west ratio: ", 2.5, " got: ", gm.GetWestRatio())
+	}
+
+	if gm.GetDepth() != 2 {
+		t.Error("Expected depth: ", 2, " got: ", gm.GetDepth())
+	}
+
+	// Path 0 1 4 takes 57.8 while path 3 4 takes only 34.8
+	if !AreTaskIdsSame(gm.GetCriticalPath(), []TaskId_t{0, 1, 4}) {
+		t.Error("Expected critical path 0 1 4 got: " + taskIdsToStr(gm.GetCriticalPath()))
+	}
+
+	if gm.GetCriticalPathTime() != 12.5+20.0+25.3 {
+		t.Error("Expected critical path time: ", 12.5+20.0+25.3, " got: ", gm.GetCriticalPathTime())
+	}
+}
+
+func TestRedundantPrereqs(t *testing.T) {
+	// ##########
+	// Task 4 lists 0 which is implied by 1, and lists 3 twice
+	file_tasks := []string{"0,1.0,nil",
+		"1,1.0,0",
+		"2,2.25,nil",
+		"3,1.0,2",
+		"4,1.0,1 0 3 3"}
+	tc.FillFrom(file_tasks)
+	defer tc.Clear()
+
+	redundant := tc.GetGraph().GetRedundantPrereqs()
+
+	var got []string
+	for i, _ := range redundant {
+		got = append(got, redundant[i].ToStr())
+	}
+	expected := []string{"4:0<1", "4:3<3"}
+	if !AreStringsSame(got, expected) {
+		t.Error("Expected redundant prereqs: ", expected, " got: ", got)
+	}
+
+	reduced := tc.ToReducedStrArr()
+	expected = []string{"0,1.0,nil", "1,1.0,0", "2,2.25,nil", "3,1.0,2", "4,1.0,1 3"}
+	if !AreStringsSame(reduced, expected) {
+		t.Error("Expected reduced spec: ", expected, " got: ", reduced)
+	}
+}
+
+///////////////////////////////////
+// Testing SST
+///////////////////////////////////
+
+// Makes a spec of randomly timed tasks where each task has up to three prereqs
+// among the twenty tasks listed before it
+func makeRandomSpec(num_tasks int, seed int64) []string {
+	rng := rand.New(rand.NewSource(seed))
+
+	var task_strings []string
+	for i := 0; i < num_tasks; i++ {
+		task := NewTask(TaskId_t(i), float64(1+rng.Intn(25)))
+		for j := rng.Intn(4); j > 0 && i > 0; j-- {
+			window := i
+			if window > 20 {
+				window = 20
+			}
+			prereqid := TaskId_t(i - 1 - rng.Intn(window))
+			if indexOfTaskId(task.prereqs, prereqid) == -1 {
+				task.prereqs = append(task.prereqs, prereqid)
+			}
+		}
+		task_strings = append(task_strings, task.ToStr())
+	}
+	return task_strings
+}
+
+// The original SST implementation which scans every assignment for prereqs and
+// every station for capacity on each step. Kept as a reference for testing and
+// benchmarking SolveSST, it fills the global workstation container directly.
+// Ties in task time are left to the unstable sort as they always were.
+func computeSolutionSSTLegacy() *PartialSolution {
+	tc := GetTaskContainer()
+	wsc := GetWorkstationContainer()
+	unassigned := []*Task{}
+	sol := PartialSolution{}
+
+	// Fill container of unassigned task ids
+	for i, _ := range tc.tasks {
+		unassigned = append(unassigned, &tc.tasks[i])
+	}
+
+	// Sort unassigned tasks ids on task time
+	sort.Slice(unassigned, func(i, j int) bool {
+		lhs_cost := unassigned[i].cost
+		rhs_cost := unassigned[j].cost
+		return lhs_cost < rhs_cost
+	})
+
+	// ############################################
+	// Assign tasks to stations using SST heuristic
+
+	// Problem writeup states that workstations and tasks start with common index
+	// The lowest id of workstation that can fit some unassigned task
+	min_ws_id := WorkstationId_t(tc.tasks[0].id)
+	// The highest id of workstations to check for met prereqs
+	max_ws_id := WorkstationId_t(tc.tasks[0].id)
+	// The current id of workstations targetted to fill
+	cur_ws_id := WorkstationId_t(tc.tasks[0].id)
+
+	// Assign tasks until there are none left unassigned
+	for len(unassigned) != 0 {
+		min_cost := unassigned[0].cost
+
+		// Increment the lowest workstation to check, if needed
+		for min_cost > workstationCapacityRemaining(wsc.workstations[min_ws_id]) {
+			min_ws_id++
+			if min_ws_id > max_ws_id {
+				max_ws_id++
+			}
+		}
+		cur_ws_id = min_ws_id
+
+		// There is at least one task that can be assigned in the workstation range
+		// given min_cost <= capacity(max_ws_id) enforced above
+
+		// Attempt to fill lowest workstations first
+		task_assigned := false
+		grew_workstations := false
+		for cur_ws_id <= max_ws_id {
+			cur_cap := workstationCapacityRemaining(wsc.workstations[cur_ws_id])
+
+			// Assign first task found that fits in the cur workstation if possible
+			for i, task := range unassigned {
+				// Assign task if conditions met
+				if task.cost <= cur_cap && sol.TaskPrereqsMet(task, cur_ws_id) {
+					// Add assignment to solution
+					assignment := TaskAssignment{task.id, cur_ws_id}
+					sol.assignments = append(sol.assignments, assignment)
+
+					// Add task to workstation
+					wstasks := &wsc.workstations[cur_ws_id].tasks
+					*wstasks = append(*wstasks, task.id)
+
+					// remove this taskid from unassigned, linear time, sad day.
+					unassigned = append(unassigned[:i], unassigned[i+1:]...)
+
+					task_assigned = true
+					break
+				}
+			}
+
+			if task_assigned {
+				break
+			}
+
+			cur_ws_id++
+
+			if cur_ws_id > max_ws_id && !grew_workstations {
+				max_ws_id++
+				grew_workstations = true
+			}
+		}
+		if !grew_workstations && !task_assigned {
+			panic("There is was no task assigned, this should never happen")
+		}
+	}
+
+	// Validate solution found
+	if !IsSolutionValid(&sol) {
+		fmt.Println("WARNING Invalid solution detected")
+	}
+
+	// Perform qualitative analysis on solution found
+
+	return &sol
+}
+
+func TestSolveSSTMatchesLegacy(t *testing.T) {
+	// ##########
+	// The station oriented SST must make the same assignments as the original scan
+	var specs [][]string
+	for i := 1; i <= 5; i++ {
+		specs = append(specs, ReadSpecFromPath("../../specs/spec"+strconv.Itoa(i)+".txt"))
+	}
+	for seed := int64(0); seed < 5; seed++ {
+		specs = append(specs, makeRandomSpec(300, seed))
+	}
+
+	for _, file_tasks := range specs {
+		tc.FillFrom(file_tasks)
+		wsc.FillFrom(tc)
+
+		legacy_sol := computeSolutionSSTLegacy()
+		sol := SolveSST(context.Background(), tc, nil).GetSolution()
+		if sol.ToStationsStr() != legacy_sol.ToStationsStr() {
+			t.Error("Expected assignments: " + legacy_sol.ToStationsStr() + " got: " + sol.ToStationsStr())
+		}
+
+		wsc.FillFromSolution(sol)
+		if !IsSolutionValid(sol) {
+			t.Error("Solution failed validation  " + sol.ToStr())
+		}
+
+		tc.Clear()
+		wsc.Clear()
+	}
+}
+
+func benchmarkSST(b *testing.B, num_tasks int, legacy bool) {
+	tc.FillFrom(makeRandomSpec(num_tasks, 1))
+	defer tc.Clear()
+	wsc.FillFrom(tc)
+	defer wsc.Clear()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Building the graph is part of the cost of a solve
+		tc.graph = nil
+		if legacy {
+			wsc.clearAllStationsTasks()
+			computeSolutionSSTLegacy()
+		} else {
+			ComputeSolutionSST(context.Background(), nil)
+		}
+	}
+}
+
+// The legacy scan takes seconds per solve at 1000 tasks so it isn't run on the
+// larger instances
+func BenchmarkSST300(b *testing.B)        { benchmarkSST(b, 300, false) }
+func BenchmarkSST1000(b *testing.B)       { benchmarkSST(b, 1000, false) }
+func BenchmarkSST10000(b *testing.B)      { benchmarkSST(b, 10000, false) }
+func BenchmarkSST50000(b *testing.B)      { benchmarkSST(b, 50000, false) }
+func BenchmarkSSTLegacy300(b *testing.B)  { benchmarkSST(b, 300, true) }
+func BenchmarkSSTLegacy1000(b *testing.B) { benchmarkSST(b, 1000, true) }
+
+///////////////////////////////////
+// Testing generator
+///////////////////////////////////
+
+func TestGenerateSpec(t *testing.T) {
+	// ##########
+	cfg := GeneratorConfig{NumTasks: 120, OrderStrength: 0.4, Times: TimesBimodal, CycleTime: 80.0, Seed: 7}
+	file_tasks, err := GenerateSpec(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, _ := GenerateSpec(cfg)
+	if !AreStringsSame(file_tasks, again) {
+		t.Error("Expected the same spec from the same seed")
+	}
+
+	cfg.Seed = 8
+	other, _ := GenerateSpec(cfg)
+	if AreStringsSame(file_tasks, other) {
+		t.Error("Expected a different spec from a different seed")
+	}
+
+	tc.FillFrom(file_tasks)
+	defer tc.Clear()
+
+	if len(tc.tasks) != cfg.NumTasks {
+		t.Error("Expected ", cfg.NumTasks, " tasks got: ", len(tc.tasks))
+	}
+
+	// The last link added may overshoot the target by a little
+	gm := tc.GetGraphMetrics()
+	if gm.GetOrderStrength() < 0.4 || gm.GetOrderStrength() > 0.45 {
+		t.Error("Expected order strength near 0.4 got: ", gm.GetOrderStrength())
+	}
+
+	if len(tc.GetGraph().GetRedundantPrereqs()) != 0 {
+		t.Error("Expected generated spec to list no redundant prereqs")
+	}
+
+	for i, _ := range tc.tasks {
+		if tc.tasks[i].cost <= 0.0 || tc.tasks[i].cost > cfg.CycleTime {
+			t.Error("Task time out of range in task " + tc.tasks[i].ToStr())
+		}
+	}
+
+	if _, err := ParseTimeDistribution("normal"); err == nil {
+		t.Error("Expected unknown distribution to be rejected")
+	}
+}
+
+///////////////////////////////////
+// Testing bench
+///////////////////////////////////
+
+func TestLowerBound(t *testing.T) {
+	// ##########
+	// Total time needs 2 stations but three tasks over half the cycle time need 3
+	tc.FillFrom([]string{"0,26.0,nil", "1,26.0,nil", "2,26.0,nil"})
+	defer tc.Clear()
+
+	if tc.GetLowerBound() != 3 {
+		t.Error("Expected lower bound: ", 3, " got: ", tc.GetLowerBound())
+	}
+
+	// Five tasks over a third of the cycle time need 3 stations, total time 2
+	tc.Clear()
+	tc.FillFrom([]string{"0,17.0,nil", "1,17.0,nil", "2,17.0,nil", "3,17.0,nil", "4,17.0,nil"})
+	if tc.GetLowerBound() != 3 {
+		t.Error("Expected lower bound: ", 3, " got: ", tc.GetLowerBound())
+	}
+}
+
+func TestRunBench(t *testing.T) {
+	// ##########
+	spec_dir, err := ioutil.TempDir("", "pwlb_bench")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(spec_dir)
+
+	WriteSpecToPath(filepath.Join(spec_dir, "spec1.txt"), test_spec1)
+
+	// Same graph as spec1 in the Scholl format with tasks numbered from 1
+	scholl := "5\n12.5\n20\n2.3\n9.5\n25.3\n1,2\n2,5\n4,5\n-1,-1\n"
+	ioutil.WriteFile(filepath.Join(spec_dir, "SPEC1.IN2"), []byte(scholl), 0644)
+	ioutil.WriteFile(filepath.Join(spec_dir, "notes.md"), []byte("not a spec"), 0644)
+
+	optima_path := filepath.Join(spec_dir, "optima.csv")
+	ioutil.WriteFile(optima_path, []byte("# known optima\nspec1.txt,50,2\n"), 0644)
+
+	results, err := RunBench(BenchConfig{SpecDir: spec_dir, Solvers: []string{"sst"},
+		CycleTime: 50.0, OptimaPath: optima_path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 {
+		t.Fatal("Expected results for 2 specs got: ", len(results))
+	}
+	for i, _ := range results {
+		// Two stations meets the lower bound so the solve is known to be optimal
+		if results[i].GetStatus() != "optimal" || results[i].GetStations() != 2 {
+			t.Error("Expected 2 stations solved optimal got: ", results[i].toFields())
+		}
+	}
+	if results[0].optimum != 0 || results[1].optimum != 2 {
+		t.Error("Expected only spec1.txt to have a known optimum")
+	}
+
+	// A spec with a prereq cycle fails without stopping the others
+	WriteSpecToPath(filepath.Join(spec_dir, "cycle.txt"), []string{"1,10.0,2", "2,10.0,1"})
+	results, err = RunBench(BenchConfig{SpecDir: spec_dir, Solvers: []string{"sst"}, CycleTime: 50.0})
+	if err != nil || len(results) != 3 || results[1].GetStatus() != "failed" || results[2].GetStatus() != "optimal" {
+		t.Error("Expected the spec with a cycle to fail got: ", results)
+	}
+
+	if _, err := RunBench(BenchConfig{SpecDir: spec_dir, Solvers: []string{"nope"}}); err == nil {
+		t.Error("Expected unknown solver to be rejected")
+	}
+}
+
+func TestSolveSSTStatus(t *testing.T) {
+	// ##########
+	tc.FillFrom(test_spec1)
+	defer tc.Clear()
+
+	// Two stations meets the lower bound
+	result := SolveSST(context.Background(), tc, nil)
+	if result.GetStatus() != SolveOptimal {
+		t.Error("Expected status optimal got: " + result.GetStatus().ToStr())
+	}
+
+	// Eight stations with a lower bound of seven can't be proven optimal
+	tc.Clear()
+	tc.FillFrom(ReadSpecFromPath("../../specs/spec5.txt"))
+	result = SolveSST(context.Background(), tc, nil)
+	if result.GetStatus() != SolveCompleted || result.GetSolution().GetMeasuredMin() != 8 {
+		t.Error("Expected 8 stations completed got: ", result.GetSolution().GetMeasuredMin(),
+			" "+result.GetStatus().ToStr())
+	}
+
+	// A cancelled context stops the solve before any station is filled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result = SolveSST(ctx, tc, nil)
+	if result.GetStatus() != SolveInterrupted || len(result.GetSolution().assignments) != 0 {
+		t.Error("Expected an interrupted solve with no assignments got: " + result.GetSolution().ToStr())
+	}
+
+	// An expired deadline counts the same as a cancel
+	ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	if SolveSST(ctx, tc, nil).GetStatus() != SolveInterrupted {
+		t.Error("Expected an interrupted solve past the deadline")
+	}
+}
+
+func TestSolveObserver(t *testing.T) {
+	// ##########
+	tc.FillFrom(ReadSpecFromPath("../../specs/spec5.txt"))
+	defer tc.Clear()
+
+	var events []SolveEvent
+	opts := &SolveOptions{Observer: func(ev *SolveEvent) {
+		events = append(events, *ev)
+	}}
+	result := SolveSST(context.Background(), tc, opts)
+
+	// The bound comes first, then a station per iteration, then the solution
+	if len(events) != 10 {
+		t.Fatal("Expected 10 events got: ", len(events))
+	}
+	if events[0].GetKind() != EventBound || events[0].GetLowerBound() != 7 {
+		t.Error("Expected a lower bound of 7 first got: ", events[0].GetLowerBound())
+	}
+	for i := 1; i < 9; i++ {
+		if events[i].GetKind() != EventIteration || events[i].GetIterations() != i {
+			t.Error("Expected iteration ", i, " got: ", events[i].GetIterations())
+		}
+		if events[i].GetIncumbent() != nil || events[i].GetLowerBound() != 7 {
+			t.Error("Expected iteration events to carry the bound and no incumbent")
+		}
+	}
+	last := events[9]
+	if last.GetKind() != EventIncumbent || last.GetIncumbent() != result.GetSolution() {
+		t.Error("Expected the solution as the last event")
+	}
+	if last.GetElapsed() < events[0].GetElapsed() {
+		t.Error("Expected elapsed time to never go back")
+	}
+
+	// No observer is fine too
+	if SolveSST(context.Background(), tc, &SolveOptions{}).GetSolution().GetMeasuredMin() != 8 {
+		t.Error("Expected 8 stations without an observer")
+	}
+}
+
+func TestSolveRPW(t *testing.T) {
+	// ##########
+	tc.FillFrom(ReadSpecFromPath("../../specs/spec5.txt"))
+	defer tc.Clear()
+
+	// RPW meets the lower bound where SST needs a station more
+	result := SolveRPW(context.Background(), tc, nil)
+	if err := result.GetSolution().Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if result.GetStatus() != SolveOptimal || result.GetSolution().GetMeasuredMin() != 7 {
+		t.Error("Expected 7 stations optimal got: ", result.GetSolution().GetMeasuredMin(),
+			" "+result.GetStatus().ToStr())
+	}
+}
+
+func TestBranchAndBound(t *testing.T) {
+	// ##########
+	tc.FillFrom(ReadSpecFromPath("../../specs/spec2.txt"))
+	defer tc.Clear()
+
+	result := SolveBranchAndBound(context.Background(), tc, nil)
+	if err := result.GetSolution().Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if result.GetStatus() != SolveOptimal || result.GetSolution().GetMeasuredMin() != 7 {
+		t.Error("Expected 7 stations optimal got: ", result.GetSolution().GetMeasuredMin(),
+			" "+result.GetStatus().ToStr())
+	}
+
+	// The long task in the middle of the chain can't share a station so three
+	// are needed, more than the lower bound of two. The search has to run to the
+	// end to prove it.
+	tc.Clear()
+	tc.FillFrom([]string{"0,10.0,nil", "1,45.0,0", "2,10.0,1"})
+	if tc.GetLowerBound() != 2 {
+		t.Fatal("Expected a lower bound of 2 got: ", tc.GetLowerBound())
+	}
+	result = SolveBranchAndBound(context.Background(), tc, nil)
+	if result.GetStatus() != SolveOptimal || result.GetSolution().GetMeasuredMin() != 3 {
+		t.Error("Expected 3 stations optimal got: ", result.GetSolution().GetMeasuredMin(),
+			" "+result.GetStatus().ToStr())
+	}
+}
+
+func TestPortfolio(t *testing.T) {
+	// ##########
+	tc.FillFrom(ReadSpecFromPath("../../specs/spec5.txt"))
+	defer tc.Clear()
+
+	var events []SolveEvent
+	opts := &SolveOptions{Observer: func(ev *SolveEvent) {
+		events = append(events, *ev)
+	}}
+	portfolio, err := GetSolver("portfolio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := portfolio(context.Background(), tc, opts)
+	if err := result.GetSolution().Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if result.GetStatus() != SolveOptimal || result.GetSolution().GetMeasuredMin() != 7 {
+		t.Error("Expected 7 stations optimal got: ", result.GetSolution().GetMeasuredMin(),
+			" "+result.GetStatus().ToStr())
+	}
+
+	// Only improving incumbents are passed on
+	last_stations := 0
+	for i, _ := range events {
+		if events[i].GetKind() != EventIncumbent {
+			continue
+		}
+		stations := events[i].GetIncumbent().GetMeasuredMin()
+		if last_stations != 0 && stations >= last_stations {
+			t.Error("Expected improving incumbents got: ", stations, " after ", last_stations)
+		}
+		last_stations = stations
+	}
+	if last_stations != 7 {
+		t.Error("Expected a last incumbent of 7 stations got: ", last_stations)
+	}
+
+	// Branch and bound can't finish on spec4 in time but the heuristics give a
+	// complete solution
+	tc.Clear()
+	tc.FillFrom(ReadSpecFromPath("../../specs/spec4.txt"))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result = portfolio(ctx, tc, nil)
+	if result.GetStatus() != SolveInterrupted || result.GetSolution().Validate() != nil {
+		t.Error("Expected an interrupted solve with a complete solution")
+	}
+	rpw_stations := SolveRPW(context.Background(), tc, nil).GetSolution().GetMeasuredMin()
+	if result.GetSolution().GetMeasuredMin() > rpw_stations {
+		t.Error("Expected no more stations than RPW got: ", result.GetSolution().GetMeasuredMin())
+	}
+
+	if _, err := NewPortfolioSolver([]string{"sst", "nope"}); err == nil {
+		t.Error("Expected unknown solver to be rejected")
+	}
+}
+
+func TestAnnealingAndGenetic(t *testing.T) {
+	// ##########
+	tc.FillFrom(ReadSpecFromPath("../../specs/spec3.txt"))
+	defer tc.Clear()
+
+	// RPW needs 50 stations, both searches meet the lower bound of 49
+	for _, name := range []string{"sa", "ga"} {
+		solver, _ := GetSolver(name)
+		iterations := 0
+		opts := &SolveOptions{Observer: func(ev *SolveEvent) {
+			iterations = ev.GetIterations()
+		}}
+		result := solver(context.Background(), tc, opts)
+		if err := result.GetSolution().Validate(); err != nil {
+			t.Fatal(name + ": " + err.Error())
+		}
+		if result.GetStatus() != SolveOptimal || result.GetSolution().GetMeasuredMin() != 49 || iterations == 0 {
+			t.Error(name+": expected 49 stations optimal got: ", result.GetSolution().GetMeasuredMin())
+		}
+		again := solver(context.Background(), tc, nil)
+		if again.GetSolution().ToStationsStr() != result.GetSolution().ToStationsStr() {
+			t.Error(name + ": expected the same solution on every solve")
+		}
+	}
+
+	// Pins are kept by decoding like they are by RPW
+	tc.Clear()
+	tc.FillFrom([]string{"1,20.0,nil", "2,20.0,1", "3,20.0,1,station=1", "4,20.0,2 3", "5,20.0,4"})
+	for _, name := range []string{"sa", "ga"} {
+		solver, _ := GetSolver(name)
+		if sol := solver(context.Background(), tc, nil).GetSolution(); sol.Validate() != nil || sol.GetMeasuredMin() != 3 {
+			t.Error(name + " expected 3 valid workstations got: " + sol.ToStationsStr())
+		}
+	}
+}
+
+func TestULine(t *testing.T) {
+	// ##########
+	// No two neighbours of the chain fit a station together, but on a U-line the
+	// first and last task can share one
+	tc.FillFrom([]string{"1,30.0,nil", "2,40.0,1", "3,20.0,2"})
+	defer tc.Clear()
+
+	if stations := SolveSST(context.Background(), tc, nil).GetSolution().GetMeasuredMin(); stations != 3 {
+		t.Error("Expected 3 stations on a straight line got: ", stations)
+	}
+
+	tc.SetLayout(LayoutU)
+	for _, name := range []string{"sst", "rpw", "bb"} {
+		solver, _ := GetSolver(name)
+		sol := solver(context.Background(), tc, nil).GetSolution()
+		if err := sol.Validate(); err != nil {
+			t.Fatal(name + ": " + err.Error())
+		}
+		if sol.GetMeasuredMin() != 2 || !sol.IsOnBack(3) || sol.IsOnBack(1) {
+			t.Error(name+": expected 2 stations with task 3 on the back got: ", sol.ToStationsStr())
+		}
+	}
+
+	// Task 3 on the front of the first station would come before its prereq
+	sol := &PartialSolution{tc: tc, back: map[TaskId_t]bool{}}
+	sol.assignments = []TaskAssignment{{1, 1}, {3, 1}, {2, 2}}
+	if sol.Validate() == nil {
+		t.Error("Expected task 3 before its prereq to be rejected")
+	}
+	sol.back[3] = true
+	if err := sol.Validate(); err != nil {
+		t.Error(err)
+	}
+	if read_sol, err := ParseSolution(tc, sol.ToSolutionLines()); err != nil || read_sol.Validate() != nil || !read_sol.IsOnBack(3) {
+		t.Error("Expected to read task 3 on the back got: ", sol.ToSolutionLines())
+	}
+
+	// Only the station with a task on the way back lists Back
+	wsc.FillFrom(tc)
+	wsc.FillFromSolution(sol)
+	pretty := PrettySolutionStr(sol)
+	wsc.Clear()
+	if strings.Count(pretty, "Back") != 1 || !strings.Contains(pretty, "Back 3") {
+		t.Error("Expected Back listed for the first station only got: ", pretty)
+	}
+
+	// A straight line has no back side
+	tc.SetLayout(LayoutStraight)
+	if sol.Validate() == nil {
+		t.Error("Expected a back side task on a straight line to be rejected")
+	}
+
+	if _, err := ParseLineLayout("v"); err == nil {
+		t.Error("Expected unknown layout to be rejected")
+	}
+}
+
+var test_spec_two_sided = []string{"1,20.0,nil,side=L",
+	"2,30.0,1,side=R",
+	"3,20.0,nil,side=R",
+	"4,25.0,2"}
+
+func TestTwoSided(t *testing.T) {
+	// ##########
+	tc.FillFrom(test_spec_two_sided)
+	defer tc.Clear()
+	tc.SetLayout(LayoutTwoSided)
+
+	if !AreStringsSame(tc.ToStrArr(), test_spec_two_sided) {
+		t.Error("Expected task sides to be written back got: ", tc.ToStrArr())
+	}
+	if tc.GetLowerBound() != 1 {
+		t.Error("Expected a lower bound of 1 got: ", tc.GetLowerBound())
+	}
+
+	// Task 2 waits on the right for task 1 on the left, which leaves no room for
+	// task 4 at the first station
+	sol := SolveSST(context.Background(), tc, nil).GetSolution()
+	if err := sol.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if sol.ToStationsStr() != "1:1 3 2,2:4" {
+		t.Error("Expected 1:1 3 2,2:4 got: " + sol.ToStationsStr())
+	}
+	if sol.GetSide(1) != SideLeft || sol.GetSide(2) != SideRight || sol.GetStart(2) != 20.0 {
+		t.Error("Expected task 2 to start on the right when task 1 finishes")
+	}
+	read_sol, err := ParseSolution(tc, sol.ToSolutionLines())
+	if err != nil || read_sol.Validate() != nil || read_sol.GetSide(2) != SideRight || read_sol.GetStart(2) != 20.0 {
+		t.Error("Expected to read the sides and starts got: ", sol.ToSolutionLines())
+	}
+	if _, err := ParseSolution(tc, []string{"1:1E@0"}); err == nil {
+		t.Error("Expected a task on neither side to be rejected")
+	}
+
+	// Moving task 2 to the start of the cycle breaks the order and overlaps task 3
+	sol.starts[2] = 0.0
+	if sol.Validate() == nil {
+		t.Error("Expected task 2 before its prereq to be rejected")
+	}
+	sol.starts[2] = 20.0
+	sol.starts[3] = 10.0
+	if sol.Validate() == nil {
+		t.Error("Expected overlapping tasks 2 and 3 to be rejected")
+	}
+	sol.starts[3] = 0.0
+	sol.sides[1] = SideRight
+	if sol.Validate() == nil {
+		t.Error("Expected task 1 on the wrong side to be rejected")
+	}
+
+	// Branch and bound is left out of a portfolio on a two-sided line
+	portfolio, _ := GetSolver("portfolio")
+	if result := portfolio(context.Background(), tc, nil); result.GetSolution().Validate() != nil ||
+		result.GetSolution().GetMeasuredMin() != 2 {
+		t.Error("Expected 2 stations from the portfolio got: " + result.GetSolution().ToStationsStr())
+	}
+	if CheckSolver("bb", tc, nil) == nil || CheckSolver("sst", tc, nil) != nil {
+		t.Error("Expected only branch and bound to be refused on a two-sided line")
+	}
+
+	if _, err := ParseTaskSide("X"); err == nil {
+		t.Error("Expected unknown side to be rejected")
+	}
+}
+
+var test_spec_mixed = []string{"1,0.0,nil",
+	"2,0.0,1",
+	"3,0.0,1",
+	"4,0.0,2 3",
+	"5,0.0,4",
+	"model,A,0.6,1:20.0 2:30.0 3:10.0 4:15.0 5:20.0",
+	"model,B,0.4,1:25.0 3:35.0 4:20.0 5:10.0"}
+
+func TestMixedModel(t *testing.T) {
+	// ##########
+	tc.FillFrom(test_spec_mixed)
+	defer tc.Clear()
+
+	// Task 2 isn't built for model B so it takes 0.6 of model A's time
+	if len(tc.GetModels()) != 2 || tc.GetTaskReadOnly(2).cost != 18.0 || tc.GetTaskReadOnly(1).cost != 22.0 {
+		t.Fatal("Expected average times of 22 and 18 got: ", tc.ToStrArr())
+	}
+
+	// Written back specs keep the models and read back the same
+	spec := tc.ToStrArr()
+	if spec[5] != test_spec_mixed[5] || spec[6] != test_spec_mixed[6] {
+		t.Error("Expected model lines written back got: ", spec[5:])
+	}
+	tc.Clear()
+	tc.FillFrom(spec)
+	if !AreStringsSame(tc.ToStrArr(), spec) {
+		t.Error("Expected the same spec read back got: ", tc.ToStrArr())
+	}
+
+	// Tasks 3 and 4 balance on average time but overrun the cycle for model B
+	sol := SolveSST(context.Background(), tc, nil).GetSolution()
+	if sol.ToStationsStr() != "1:1 2,2:3 4,3:5" {
+		t.Fatal("Expected 1:1 2,2:3 4,3:5 got: " + sol.ToStationsStr())
+	}
+	loads := sol.GetModelLoads()
+	if len(loads) != 6 || loads[0].GetModel() != "A" || loads[0].GetLoad() != 50.0 {
+		t.Error("Expected 6 model loads starting with 50 for A got: ", loads)
+	}
+	overruns := sol.GetModelOverruns()
+	if len(overruns) != 1 || overruns[0].GetModel() != "B" || overruns[0].GetStation() != 2 ||
+		overruns[0].GetLoad() != 55.0 {
+		t.Error("Expected model B to overrun station 2 got: ", overruns)
+	}
+	if !strings.Contains(PrettyModelLoadsStr(sol), "Station 2:      A 25.00   B 55.00 over\n") {
+		t.Error("Expected the overrun marked got: " + PrettyModelLoadsStr(sol))
+	}
+}
+
+func TestParallelStations(t *testing.T) {
+	// ##########
+	tc.FillFrom([]string{"1,20.0,nil", "2,80.0,1", "3,30.0,2", "4,15.0,2"})
+	defer tc.Clear()
+
+	// Task 2 needs two copies of a station, which leaves room for task 4
+	opts := &SolveOptions{MaxReplicas: 2, ReplicaCost: 0.8}
+	sol := SolveSST(context.Background(), tc, opts).GetSolution()
+	if err := sol.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if sol.ToStationsStr() != "1:1,2:2 4,3:3" || sol.GetReplicas(2) != 2 || sol.GetReplicas(1) != 1 {
+		t.Fatal("Expected 1:1,2:2 4,3:3 with station 2 replicated got: " + sol.ToStationsStr())
+	}
+
+	// Copies count as workstations with their own cycle time
+	if sol.GetMeasuredMin() != 4 || sol.GetLineEfficiencyStr() != "72.5%" {
+		t.Error("Expected 4 workstations at 72.5% got: ", sol.GetMeasuredMin(), " "+sol.GetLineEfficiencyStr())
+	}
+	if sol.GetLineCost(0.8) != 3.8 {
+		t.Error("Expected a line cost of 3.8 got: ", sol.GetLineCost(0.8))
+	}
+
+	// Saved solutions keep the copies
+	lines := sol.ToSolutionLines()
+	if !AreStringsSame(lines, []string{"1:1", "2x2:2 4", "3:3"}) {
+		t.Error("Expected 1:1 2x2:2 4 3:3 got: ", lines)
+	}
+	if read_sol, err := ParseSolution(tc, lines); err != nil || read_sol.Validate() != nil || read_sol.GetReplicas(2) != 2 {
+		t.Error("Expected to read station 2 with two copies got: ", err)
+	}
+
+	// Without the copies the station is over the cycle time
+	sol.replicas = nil
+	if sol.Validate() == nil {
+		t.Error("Expected station 2 over the cycle time to be rejected")
+	}
+
+	// Branch and bound can't replicate so the portfolio leaves it out
+	portfolio, _ := GetSolver("portfolio")
+	if result := portfolio(context.Background(), tc, opts); result.GetSolution().Validate() != nil ||
+		result.GetSolution().GetMeasuredMin() != 4 {
+		t.Error("Expected 4 workstations from the portfolio got: " + result.GetSolution().ToStationsStr())
+	}
+
+	// Three copies are too many
+	defer func() {
+		if msg := recover(); msg == nil || !strings.Contains(msg.(string), "more than the 2 allowed") {
+			t.Error("Expected a panic for a task needing more copies than allowed got: ", msg)
+		}
+	}()
+	tc.SetCycleTime(25.0)
+	SolveSST(context.Background(), tc, opts)
+}
+
+func TestZoning(t *testing.T) {
+	// ##########
+	tc.FillFrom([]string{"1,10.0,nil", "2,10.0,1", "3,30.0,1", "4,20.0,2", "5,20.0,3",
+		"together,2 5", "apart,1 3"})
+	defer tc.Clear()
+
+	// Without the zones SST gives 1:1 2 4,2:3 5
+	sol := SolveSST(context.Background(), tc, nil).GetSolution()
+	if err := sol.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if sol.ToStationsStr() != "1:1,2:3,3:2 5 4" {
+		t.Error("Expected 1:1,2:3,3:2 5 4 got: " + sol.ToStationsStr())
+	}
+
+	for _, name := range []string{"rpw", "bb", "portfolio"} {
+		solver, _ := GetSolver(name)
+		if err := solver(context.Background(), tc, nil).GetSolution().Validate(); err != nil {
+			t.Error(name + ": " + err.Error())
+		}
+	}
+
+	// Only the zone the solver would break on its own is binding
+	binding, err := tc.GetBindingZones(context.Background(), SolveSST, nil)
+	if err != nil || len(binding) != 1 || binding[0].ToStr() != "together,2 5" {
+		t.Error("Expected together,2 5 to be the only binding zone got: ", binding, err)
+	}
+
+	sol.assignments[1].j = 1
+	if sol.Validate() == nil {
+		t.Error("Expected tasks 1 and 3 at one station to be rejected")
+	}
+
+	// Task 2 has to come between tasks 1 and 4
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for a zone no solution can meet")
+		}
+	}()
+	tc.zones = []Zone{{ZoneTogether, []TaskId_t{1, 4}}}
+	SolveSST(context.Background(), tc, nil)
+}
+
+func TestPinnedTasks(t *testing.T) {
+	// ##########
+	tc.FillFrom([]string{"1,20.0,nil", "2,20.0,1", "3,20.0,1,station=1", "4,20.0,2 3", "5,20.0,4"})
+	defer tc.Clear()
+
+	pinned := tc.GetTaskReadOnly(3)
+	if pin := pinned.GetPin(); pin == nil || pin.ToStr() != "1" {
+		t.Fatal("Expected task 3 pinned to station 1")
+	}
+	if tc.ToStrArr()[2] != "3,20.0,1,station=1" {
+		t.Error("Expected 3,20.0,1,station=1 got: " + tc.ToStrArr()[2])
+	}
+
+	// Without the pin SST gives 1:1 2,2:3 4,3:5
+	sol := SolveSST(context.Background(), tc, nil).GetSolution()
+	if err := sol.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if sol.ToStationsStr() != "1:1 3,2:2 4,3:5" {
+		t.Error("Expected 1:1 3,2:2 4,3:5 got: " + sol.ToStationsStr())
+	}
+	for _, name := range []string{"rpw", "bb", "portfolio"} {
+		solver, _ := GetSolver(name)
+		if sol := solver(context.Background(), tc, nil).GetSolution(); sol.Validate() != nil || sol.GetMeasuredMin() != 3 {
+			t.Error(name + " expected 3 valid workstations got: " + sol.ToStationsStr())
+		}
+	}
+
+	sol.assignments[1].j = 2
+	if sol.Validate() == nil {
+		t.Error("Expected task 3 away from its pin to be rejected")
+	}
+
+	// Task 2 comes before task 4 so it can't be at a later station
+	tc.PinTask(2, 3, 3)
+	tc.PinTask(4, 2, 2)
+	if err := tc.CheckPins(nil); err == nil ||
+		err.Error() != "Pins can't be met, task 2 pinned to station 3 comes before task 4 pinned to station 2" {
+		t.Error("Expected the pins of tasks 2 and 4 to conflict got: ", err)
+	}
+	tc.tasks[1].pin, tc.tasks[3].pin = nil, nil
+
+	// Stations 1 to 3 each need a task before task 2 but only tasks 1 and 3 can go there
+	few_tc := NewTaskContainer()
+	few_tc.FillFrom([]string{"1,10.0,nil", "2,10.0,1,station=4", "3,10.0,nil"})
+	if err := few_tc.CheckPins(nil); err == nil || err.Error() != "Pins can't be met, task 2 pinned to station 4 "+
+		"needs a task at each of the 3 stations before it but only 2 can go there" {
+		t.Error("Expected too few tasks before station 4 got: ", err)
+	}
+
+	// Tasks 1 and 2 have to be at stations 1 to 2 with tasks 3 and 4 and don't fit
+	few_tc.SetCycleTime(15.0)
+	few_tc.PinTask(2, 2, 2)
+	few_tc.PinTask(3, 1, 2)
+	few_tc.FillFrom([]string{"4,10.0,nil,station=1-2"})
+	if err := few_tc.CheckPins(nil); err == nil || err.Error() != "Pins can't be met, tasks 1 2 3 4 "+
+		"have to be at stations 1-2 and exceed their cycle times" {
+		t.Error("Expected too much work pinned to stations 1-2 got: ", err)
+	}
+
+	// Nothing is left for station 3 before task 5
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for a station the pins leave empty")
+		}
+	}()
+	tc.PinTask(5, 4, 4)
+	SolveSST(context.Background(), tc, nil)
+}
+
+func TestRebalance(t *testing.T) {
+	// ##########
+	old_tc := NewTaskContainer()
+	old_tc.FillFrom([]string{"1,20.0,nil", "2,20.0,1", "3,20.0,1", "4,20.0,2 3"})
+	old_sol, err := ParseSolution(old_tc, []string{"1:1 2", "2:3 4"})
+	if err != nil || old_sol.Validate() != nil || old_sol.ToStationsStr() != "1:1 2,2:3 4" {
+		t.Fatal("Expected to read 1:1 2,2:3 4 got: ", err)
+	}
+	if _, err := ParseSolution(old_tc, []string{"1:1 9"}); err == nil {
+		t.Error("Expected an unknown task in a solution to be rejected")
+	}
+
+	// Task 2 takes longer and task 5 is new, SST from scratch would move task 3
+	new_tc := NewTaskContainer()
+	new_tc.FillFrom([]string{"1,20.0,nil", "2,25.0,1", "3,20.0,1", "4,20.0,2 3", "5,10.0,1"})
+	result, err := Rebalance(context.Background(), old_sol, new_tc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := result.GetSolution().Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if result.GetSolution().ToStationsStr() != "1:1 2,2:3 4 5" {
+		t.Error("Expected 1:1 2,2:3 4 5 got: " + result.GetSolution().ToStationsStr())
+	}
+	if result.Count(TaskMoved) != 0 || result.Count(TaskAdded) != 1 || PrettyRebalanceStr(result) != "Task 5:      Added at 2\n" {
+		t.Error("Expected only task 5 added got: " + PrettyRebalanceStr(result))
+	}
+
+	// Task 3 no longer fits with task 4 and has to move
+	new_tc = NewTaskContainer()
+	new_tc.FillFrom([]string{"1,20.0,nil", "2,20.0,1", "3,35.0,1", "4,20.0,2 3"})
+	result, err = Rebalance(context.Background(), old_sol, new_tc, nil)
+	if err != nil || result.GetSolution().Validate() != nil || result.Count(TaskMoved) != 1 {
+		t.Error("Expected one task moved got: " + PrettyRebalanceStr(result))
+	}
+
+	// Task 3 fits back at station 1 after task 2
+	new_tc = NewTaskContainer()
+	new_tc.FillFrom([]string{"1,10.0,nil", "2,20.0,1", "3,20.0,1", "4,20.0,2 3"})
+	moved_sol, _ := ParseSolution(new_tc, []string{"1:1 2", "2:3 4"})
+	home_sol := moveHome(context.Background(), moved_sol, map[TaskId_t]WorkstationId_t{3: 1})
+	if home_sol.ToStationsStr() != "1:1 2 3,2:4" {
+		t.Error("Expected 1:1 2 3,2:4 got: " + home_sol.ToStationsStr())
+	}
+
+	new_tc.SetLayout(LayoutTwoSided)
+	if _, err := Rebalance(context.Background(), old_sol, new_tc, nil); err == nil {
+		t.Error("Expected a two-sided line to be rejected")
+	}
+
+	// On a U-line task 3 stays on the back of station 1 when task 2 gets longer
+	u_spec := []string{"1,30.0,nil", "2,40.0,1", "3,20.0,2"}
+	old_tc = NewTaskContainer()
+	old_tc.FillFrom(u_spec)
+	u_sol, _ := ParseSolution(old_tc, []string{"1:1 3b", "2:2"})
+	if _, err := Rebalance(context.Background(), u_sol, new_tc, nil); err == nil ||
+		!strings.Contains(err.Error(), "back side") {
+		t.Error("Expected a back side task on a straight line to be rejected got: ", err)
+	}
+	old_tc.SetLayout(LayoutU)
+	new_tc = NewTaskContainer()
+	new_tc.FillFrom([]string{"1,30.0,nil", "2,45.0,1", "3,20.0,2"})
+	new_tc.SetLayout(LayoutU)
+	result, err = Rebalance(context.Background(), u_sol, new_tc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := result.GetSolution().Validate(); err != nil || result.Count(TaskMoved) != 0 ||
+		!result.GetSolution().IsOnBack(3) {
+		t.Error("Expected task 3 kept on the back got: ", result.GetSolution().ToSolutionLines())
+	}
+}
+
+func TestDiffSolutions(t *testing.T) {
+	// ##########
+	diff_tc := NewTaskContainer()
+	diff_tc.FillFrom([]string{"1,20.0,nil", "2,20.0,1", "3,20.0,1", "4,20.0,2 3"})
+	before, _ := ParseSolution(diff_tc, []string{"1:1 2,2:3,3:4"})
+	after, _ := ParseSolution(diff_tc, []string{"1:1 2", "2:3 4"})
+
+	diff, err := DiffSolutions(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if PrettyDiffStr(diff) != "Station 3:      Removed\nTask 4:      Moved 3 -> 2\n" {
+		t.Error("Expected station 3 removed and task 4 moved got: " + PrettyDiffStr(diff))
+	}
+	if diff.GetMeasuredMinChange() != -1 || !strings.Contains(PrettyDiffMetricsStr(diff),
+		"line_efficiency=53.3% -> 80.0% (+26.7)") {
+		t.Error("Expected one station fewer at 80.0% got: " + PrettyDiffMetricsStr(diff))
+	}
+
+	partial, _ := ParseSolution(diff_tc, []string{"1:1 2 3"})
+	if _, err := DiffSolutions(before, partial); err == nil {
+		t.Error("Expected a solution without task 4 to be rejected")
+	}
+
+	// Task 3 twice makes up for the missing task 4
+	twice, _ := ParseSolution(diff_tc, []string{"1:1 2 3", "2:3"})
+	if _, err := DiffSolutions(before, twice); err == nil || err.Error() != "Task 3 is assigned more than once after" {
+		t.Error("Expected task 3 assigned twice to be rejected got: ", err)
+	}
+	if _, err := DiffSolutions(twice, before); err == nil || err.Error() != "Task 3 is assigned more than once before" {
+		t.Error("Expected task 3 assigned twice to be rejected got: ", err)
+	}
+}
+
+func TestServiceLevel(t *testing.T) {
+	// ##########
+	tc.FillFrom([]string{"1,20.0,nil,sd=3", "2,20.0,1,sd=3", "3,8.0,2"})
+	defer tc.Clear()
+
+	if tc.ToStrArr()[0] != "1,20.0,nil,sd=3" || !tc.HasStochasticTimes() {
+		t.Error("Expected 1,20.0,nil,sd=3 got: " + tc.ToStrArr()[0])
+	}
+
+	// On mean times everything fits one station, which finishes in time only about
+	// two cycles in three
+	sol := SolveSST(context.Background(), tc, nil).GetSolution()
+	if sol.ToStationsStr() != "1:1 2 3" {
+		t.Fatal("Expected 1:1 2 3 got: " + sol.ToStationsStr())
+	}
+	completions := sol.GetStationCompletions()
+	if len(completions) != 1 || math.Abs(completions[0].GetProbability()-0.681) > 0.001 ||
+		math.Abs(completions[0].GetSD()-math.Sqrt(18.0)) > 1e-9 {
+		t.Error("Expected station 1 to finish in 68.1% of cycles got: ", completions)
+	}
+
+	// At 95% task 3 no longer fits
+	opts := &SolveOptions{ServiceLevel: 0.95}
+	for _, name := range []string{"sst", "rpw", "bb", "portfolio"} {
+		solver, _ := GetSolver(name)
+		sol := solver(context.Background(), tc, opts).GetSolution()
+		if sol.ToStationsStr() != "1:1 2,2:3" {
+			t.Error(name + " expected 1:1 2,2:3 got: " + sol.ToStationsStr())
+		}
+		if sol.GetLineCompletion() < 0.95 {
+			t.Error(name+" expected every station to finish in 95% of cycles got: ", sol.GetLineCompletion())
+		}
+	}
+
+	if CheckServiceLevel(0.3) == nil || CheckServiceLevel(0.0) != nil {
+		t.Error("Expected only service levels from 0.5 to be accepted")
+	}
+
+	// A task too likely to overrun a station alone is blamed on the service level
+	tc.SetCycleTime(21.0)
+	func() {
+		defer func() {
+			if msg := recover(); msg == nil || !strings.Contains(msg.(string), "service level 0.99") {
+				t.Error("Expected a panic naming the service level got: ", msg)
+			}
+		}()
+		SolveSST(context.Background(), tc, &SolveOptions{ServiceLevel: 0.99})
+	}()
+}
+
+func TestSimulate(t *testing.T) {
+	// ##########
+	tc.FillFrom([]string{"1,20.0,nil,sd=3", "2,20.0,1,sd=3,dist=lognormal", "3,8.0,2"})
+	defer tc.Clear()
+
+	if tc.ToStrArr()[1] != "2,20.0,1,sd=3,dist=lognormal" {
+		t.Error("Expected 2,20.0,1,sd=3,dist=lognormal got: " + tc.ToStrArr()[1])
+	}
+
+	sol, err := ParseSolution(tc, []string{"1:1 2 3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := SimulationConfig{Cycles: 20000, Seed: 7}
+	sr, err := Simulate(sol, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The station overruns about as often as the normal approximation says
+	overrun := sr.GetOverrunRates()[0]
+	if math.Abs(overrun-(1.0-sol.GetLineCompletion())) > 0.03 {
+		t.Error("Expected the station to overrun in about 31.9% of cycles got: ", overrun)
+	}
+	if sr.GetMeanCycleTime() <= tc.GetCycleTime() || sr.GetEfficiency() >= sol.GetLineEfficiency() {
+		t.Error("Expected overruns to slow the line got: ", sr.GetMeanCycleTime())
+	}
+	if math.Abs(sr.GetStations()[0].GetMeanLoad()-48.0) > 0.2 {
+		t.Error("Expected a mean station time of 48 got: ", sr.GetStations()[0].GetMeanLoad())
+	}
+
+	again, _ := Simulate(sol, cfg)
+	if again.GetMeanCycleTime() != sr.GetMeanCycleTime() {
+		t.Error("Expected the same seed to give the same result")
+	}
+
+	// With room to spare nothing overruns and the line keeps its pace
+	sol, _ = ParseSolution(tc, []string{"1:1", "2:2 3"})
+	sr, _ = Simulate(sol, cfg)
+	if sr.GetThroughputShare() != 1.0 || sr.GetStations()[1].GetOverruns() != 0 ||
+		sr.GetBottleneckRates()[0]+sr.GetBottleneckRates()[1] != 1.0 {
+		t.Error("Expected the line to keep its pace got: ", sr.GetThroughputShare())
+	}
+
+	if _, err := Simulate(sol, SimulationConfig{}); err == nil {
+		t.Error("Expected a simulation without cycles to fail")
+	}
+
+	// The copies read with a solution share the station's load
+	sol, _ = ParseSolution(tc, []string{"1x2:1 2 3"})
+	if sr, _ = Simulate(sol, cfg); sr.GetThroughputShare() != 1.0 || sr.GetOverrunRates()[0] != 0.0 {
+		t.Error("Expected two copies of the station to keep the pace got: ", sr.GetThroughputShare())
+	}
+
+	tc.SetLayout(LayoutTwoSided)
+	if _, err := Simulate(sol, cfg); err == nil {
+		t.Error("Expected a two-sided line to be rejected")
+	}
+}
+
+func TestSimulateFlow(t *testing.T) {
+	// ##########
+	tc.FillFrom([]string{"1,10.0,nil", "2,40.0,1", "3,10.0,2"})
+	defer tc.Clear()
+	tc.SetCycleTime(30.0)
+
+	// Station 2 overruns every cycle so the line runs at its pace, blocking
+	// station 1 and starving station 3
+	sol, _ := ParseSolution(tc, []string{"1:1", "2:2", "3:3"})
+	for _, paced := range []bool{false, true} {
+		fr, err := SimulateFlow(sol, FlowConfig{Units: 1000, Paced: paced})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(fr.GetThroughputShare()-0.75) > 0.01 {
+			t.Error("Expected the line to run at 75% of its pace got: ", fr.GetThroughputShare())
+		}
+		for i, _ := range fr.GetStations() {
+			busy, blocked, starved := fr.GetShares(i)
+			if math.Abs(busy+blocked+starved-1.0) > 1e-9 {
+				t.Error("Expected the shares of station ", i+1, " to add up got: ", busy, blocked, starved)
+			}
+		}
+		if busy, _, _ := fr.GetShares(1); math.Abs(busy-1.0) > 0.01 {
+			t.Error("Expected station 2 to be always busy got: ", busy)
+		}
+		if _, blocked, _ := fr.GetShares(0); !paced && math.Abs(blocked-0.75) > 0.01 {
+			t.Error("Expected station 1 to be blocked 75% of the time got: ", blocked)
+		}
+		if _, _, starved := fr.GetShares(2); !paced && math.Abs(starved-0.75) > 0.01 {
+			t.Error("Expected station 3 to be starved 75% of the time got: ", starved)
+		}
+	}
+
+	// Two copies of station 2 read with the solution keep up with the line
+	sol, _ = ParseSolution(tc, []string{"1:1", "2x2:2", "3:3"})
+	if fr, _ := SimulateFlow(sol, FlowConfig{Units: 1000}); math.Abs(fr.GetThroughputShare()-1.0) > 0.01 {
+		t.Error("Expected the line to keep its pace got: ", fr.GetThroughputShare())
+	}
+	tc.SetLayout(LayoutTwoSided)
+	if _, err := SimulateFlow(sol, FlowConfig{Units: 1000}); err == nil {
+		t.Error("Expected a two-sided line to be rejected")
+	}
+	tc.SetLayout(LayoutStraight)
+
+	// Buffers let stations with varying times catch up with each other
+	tc.FillFrom([]string{"1,45.0,nil,sd=5", "2,45.0,1,sd=5", "3,45.0,2,sd=5"})
+	tc.SetCycleTime(50.0)
+	sol, _ = ParseSolution(tc, []string{"1:1", "2:2", "3:3"})
+	no_buffers, _ := SimulateFlow(sol, FlowConfig{Units: 5000, Seed: 3})
+	buffers, _ := SimulateFlow(sol, FlowConfig{Units: 5000, Buffers: []int{2}, Seed: 3})
+	paced, _ := SimulateFlow(sol, FlowConfig{Units: 5000, Paced: true, Seed: 3})
+	if !(paced.GetThroughput() < no_buffers.GetThroughput() && no_buffers.GetThroughput() <= buffers.GetThroughput()) {
+		t.Error("Expected buffers to raise the throughput got: ",
+			paced.GetThroughput(), no_buffers.GetThroughput(), buffers.GetThroughput())
+	}
+	if math.Abs(paced.GetMeanWIP()-3.0) > 0.01 {
+		t.Error("Expected a paced line to hold a unit at each station got: ", paced.GetMeanWIP())
+	}
+	again, _ := SimulateFlow(sol, FlowConfig{Units: 5000, Buffers: []int{2}, Seed: 3})
+	if again.GetTotalTime() != buffers.GetTotalTime() {
+		t.Error("Expected the same seed to give the same result")
+	}
+
+	if _, err := SimulateFlow(sol, FlowConfig{Units: 10, Buffers: []int{1, 2, 3}}); err == nil {
+		t.Error("Expected three buffers between three stations to fail")
+	}
+}
+
+func TestSetupTimes(t *testing.T) {
+	// ##########
+	tc.FillFrom([]string{"1,10.0,nil", "2,10.0,nil", "3,10.0,nil", "4,11.0,1 2 3", "5,10.0,4",
+		"setup,2,3,5", "setup,1,2,5", "setup,3,1,5", "setup,2,1,1", "setup,1,3,1", "setup,3,2,1"})
+	defer tc.Clear()
+
+	strs := tc.ToStrArr()
+	if strs[5] != "setup,1,2,5.0" || strs[10] != "setup,3,2,1.0" || tc.GetSetupTime(2, 3) != 5.0 {
+		t.Error("Expected setups listed by task got: " + strings.Join(strs[5:], " "))
+	}
+
+	// Done in the order they are listed the three tasks need 15 of setups, done
+	// the other way round only 3
+	sol, _ := ParseSolution(tc, []string{"1:1 2 3", "2:4 5"})
+	if sol.GetSetupTime() != 15.0 || sol.Validate() != nil {
+		t.Error("Expected a valid solution with 15 of setups got: ", sol.GetSetupTime(), sol.Validate())
+	}
+	if math.Abs(sol.GetSmoothnessIndex()-math.Sqrt(5.0*5.0+29.0*29.0)) > 1e-9 {
+		t.Error("Expected the setups to count in the smoothness index got: ", sol.GetSmoothnessIndex())
+	}
+	sol, _ = ParseSolution(tc, []string{"1:1 2 3 4", "2:5"})
+	if sol.Validate() == nil {
+		t.Error("Expected the setups to exceed the cycle time of station 1")
+	}
+	sol, _ = ParseSolution(tc, []string{"1:4 3 2 1", "2:5"})
+	if sol.Validate() == nil {
+		t.Error("Expected task 4 before its prereqs to fail")
+	}
+
+	// The heuristics sequence each station to fit task 4 with the others
+	for _, name := range []string{"sst", "rpw"} {
+		solver, _ := GetSolver(name)
+		sol := solver(context.Background(), tc, nil).GetSolution()
+		if sol.ToStationsStr() != "1:3 2 1 4,2:5" || sol.GetSetupTime() != 2.0 {
+			t.Error(name + " expected 1:3 2 1 4,2:5 got: " + sol.ToStationsStr())
+		}
+		if err := sol.Validate(); err != nil {
+			t.Error(name+" expected a valid solution got: ", err)
+		}
+	}
+
+	// Branch and bound keeps the order it adds tasks in but still meets the setups
+	sol = SolveBranchAndBound(context.Background(), tc, nil).GetSolution()
+	if err := sol.Validate(); err != nil || sol.GetMeasuredMin() != 2 {
+		t.Error("Expected a valid solution on 2 stations got: ", sol.ToStationsStr(), err)
+	}
+}
+
+func TestAssignCrew(t *testing.T) {
+	// ##########
+	tc.FillFrom([]string{"0,12.5,nil", "1,20.0,0", "2,2.3,nil", "3,9.5,nil", "4,25.3,1 3",
+		"worker,Ann", "worker,Bo,4=20 1=30", "worker,Cy,0=x 1=x 2=4 3=14"})
+	defer tc.Clear()
+
+	strs := tc.ToStrArr()
+	if strs[6] != "worker,Bo,1=30.0 4=20.0" || strs[7] != "worker,Cy,0=x 1=x 2=4.0 3=14.0" {
+		t.Error("Expected workers listed with their times got: " + strings.Join(strs[5:], " "))
+	}
+
+	// Ann and Bo are as quick on the first tasks but only Ann is quick on task 1
+	result, err := AssignCrew(context.Background(), tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sol := result.GetSolution()
+	if math.Abs(result.GetCycleTime()-25.3) > 1e-9 || sol.ToStationsStr() != "0:0 3 2,1:1,2:4" ||
+		sol.GetWorker(0) != "Bo" || sol.GetWorker(1) != "Ann" || sol.GetWorker(2) != "Cy" {
+		t.Error("Expected 0:0 3 2,1:1,2:4 at 25.3 by Bo, Ann and Cy got: " + sol.ToStationsStr())
+	}
+	tc.SetCycleTime(result.GetCycleTime())
+	if err := sol.Validate(); err != nil {
+		t.Error("Expected a valid solution got: ", err)
+	}
+
+	// Cy can't do task 1
+	sol.workers[0], sol.workers[1] = sol.workers[1], 2
+	sol.workers[2] = 0
+	if sol.Validate() == nil {
+		t.Error("Expected Cy at task 1 to fail")
+	}
+
+	tc.Clear()
+	tc.FillFrom([]string{"5,10.0,nil", "worker,Di,5=x"})
+	if _, err := AssignCrew(context.Background(), tc); err == nil {
+		t.Error("Expected a task no worker can do to fail")
+	}
+}
+
+func TestMultiManned(t *testing.T) {
+	// ##########
+	tc.FillFrom([]string{"1,6.0,nil", "2,6.0,nil", "3,4.0,1", "4,4.0,2"})
+	defer tc.Clear()
+	tc.SetCycleTime(10.0)
+
+	// One operator a station needs two stations
+	result, err := SolveMultiManned(context.Background(), tc, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sol := result.GetSolution()
+	if sol.ToStationsStr() != "1:1 3,2:2 4" || result.GetStatus() != SolveOptimal {
+		t.Error("Expected 1:1 3,2:2 4 got: " + sol.ToStationsStr())
+	}
+
+	// Two operators do both chains side by side at one station
+	result, err = SolveMultiManned(context.Background(), tc, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sol = result.GetSolution()
+	if sol.ToStationsStr() != "1:1 2 3 4" || sol.GetOperatorCount() != 2 || result.GetStatus() != SolveOptimal {
+		t.Error("Expected 1:1 2 3 4 with 2 operators got: " + sol.ToStationsStr())
+	}
+	if sol.GetOperator(1) != 1 || sol.GetOperator(3) != 1 || sol.GetOperator(4) != 2 || sol.GetStart(3) != 6.0 {
+		t.Error("Expected task 3 after task 1 on operator 1 and task 4 on operator 2")
+	}
+	if err := sol.Validate(); err != nil {
+		t.Error("Expected a valid solution got: ", err)
+	}
+	if sol.GetLineEfficiency() != 1.0 {
+		t.Error("Expected a line efficiency of 1 got: ", sol.GetLineEfficiency())
+	}
+	schedule := strings.Split(PrettyScheduleStr(sol), "\n")
+	if !strings.HasPrefix(schedule[1], "Station 1   Operator 2   |########################================|   2 0.00-6.00") {
+		t.Error("Expected operator 2 busy the whole cycle got: " + schedule[1])
+	}
+
+	// Task 3 can't start before task 1 finishes or overlap it on the same operator
+	sol.starts[3] = 5.0
+	if sol.Validate() == nil {
+		t.Error("Expected task 3 before its prereq to be rejected")
+	}
+	sol.starts[3] = 6.0
+	sol.operators[2] = 1
+	if sol.Validate() == nil {
+		t.Error("Expected tasks 1 and 2 overlapping on operator 1 to be rejected")
+	}
+
+	if _, err := SolveMultiManned(context.Background(), tc, 0); err == nil {
+		t.Error("Expected a station without operators to fail")
+	}
+	tc.SetCycleTime(5.0)
+	if _, err := SolveMultiManned(context.Background(), tc, 2); err == nil {
+		t.Error("Expected a task over the cycle time to fail")
+	}
+}
+
+var test_spec_costs = []string{"1,20.0,nil,equip=press",
+	"2,15.0,1,equip=weld",
+	"3,10.0,nil,equip=press",
+	"4,25.0,2 3,equip=paint",
+	"5,12.0,4,equip=weld",
+	"6,8.0,nil,equip=press",
+	"7,18.0,5 6,equip=paint",
+	"station_cost,100.0",
+	"equipment,press,300.0",
+	"equipment,weld,200.0",
+	"equipment,paint,250.0"}
+
+func TestCostOriented(t *testing.T) {
+	// ##########
+	tc.FillFrom(test_spec_costs)
+	defer tc.Clear()
+
+	if !AreStringsSame(tc.ToStrArr(), test_spec_costs) {
+		t.Error("Expected equipment and costs to be written back got: ", tc.ToStrArr())
+	}
+	if !tc.HasCosts() || tc.GetStationCost() != 100.0 || len(tc.GetEquipment()) != 3 {
+		t.Error("Expected a station cost and 3 pieces of equipment")
+	}
+
+	// RPW splits the presses and welders over two stations each
+	sol := SolveRPW(context.Background(), tc, nil).GetSolution()
+	if sol.GetTotalCost() != 1800.0 {
+		t.Error("Expected RPW to cost 1800 got: ", sol.GetTotalCost())
+	}
+
+	// The cost solver keeps every task needing a press at the first station
+	result := SolveCost(context.Background(), tc, nil)
+	sol = result.GetSolution()
+	if err := sol.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if sol.ToStationsStr() != "1:1 3 6,2:2 4,3:5 7" || result.GetStatus() != SolveCompleted {
+		t.Error("Expected 1:1 3 6,2:2 4,3:5 7 got: " + sol.ToStationsStr())
+	}
+	cb := sol.GetCostBreakdown()
+	if cb.GetTotalCost() != 1500.0 || cb.GetFixedCost() != 300.0 || cb.GetDuplicatedCost() != 450.0 {
+		t.Error("Expected a cost of 1500 with 300 fixed and 450 duplicated got: ", cb.GetTotalCost())
+	}
+	if uses := cb.GetEquipment(); len(uses) != 3 || uses[0].GetName() != "press" || uses[0].GetCopies() != 1 {
+		t.Error("Expected a single press")
+	}
+	if stations := cb.GetStations(); strings.Join(stations[1].GetEquipment(), " ") != "weld paint" ||
+		stations[1].GetTotalCost() != 550.0 {
+		t.Error("Expected a welder and a paint booth at station 2 costing 550")
+	}
+
+	// Task times that vary are left to the other solvers
+	if solverSupports("cost", tc, &SolveOptions{ServiceLevel: 0.95}) {
+		t.Error("Expected the cost solver to not solve to a service level")
+	}
+	tc.SetLayout(LayoutU)
+	if err := CheckSolver("cost", tc, nil); err == nil || !strings.Contains(err.Error(), "only solves straight lines") {
+		t.Error("Expected the cost solver to refuse a U-line got: ", err)
+	}
+	tc.SetLayout(LayoutStraight)
+	if err := tc.parseEquipment([]string{"equipment", "press", "10"}); err == nil {
+		t.Error("Expected equipment listed twice to be rejected")
+	}
+	if err := tc.parseStationCost([]string{"station_cost", "-1"}); err == nil {
+		t.Error("Expected a negative station cost to be rejected")
+	}
+}
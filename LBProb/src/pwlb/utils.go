@@ -2,6 +2,7 @@ package pwlb
 
 import (
 	"bufio"
+	"errors"
 	"log"
 	"os"
 	"path/filepath"
@@ -55,6 +56,93 @@ func ReadSpecFromPath(spec_path string) []string {
 	return readSpecFromFileBuffer(specs_file)
 }
 
+// Reads a precedence graph in the .IN2 format of the Scholl data sets and returns
+// it as spec lines. The format lists the number of tasks, one task time per line,
+// then "i,j" precedence pairs ending with "-1,-1". Tasks are numbered from 1.
+func ReadSchollSpecFromPath(spec_path string) []string {
+	specs_file, err := os.Open(spec_path)
+	if err != nil {
+		log.Fatal("Failed to read file at " + spec_path)
+	}
+	defer specs_file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(specs_file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	task_strings, err := schollToSpec(lines)
+	if err != nil {
+		log.Fatal(err.Error() + " in " + spec_path)
+	}
+	return task_strings
+}
+
+func schollToSpec(lines []string) ([]string, error) {
+	if len(lines) == 0 {
+		return nil, errors.New("Missing task count")
+	}
+	num_tasks, err := strconv.Atoi(lines[0])
+	if err != nil || num_tasks < 1 || len(lines) < num_tasks+1 {
+		return nil, errors.New("Bad task count")
+	}
+
+	tasks := make([]Task, num_tasks)
+	for i := 0; i < num_tasks; i++ {
+		cost, err := strconv.ParseFloat(lines[i+1], 64)
+		if err != nil {
+			return nil, errors.New("Bad task time " + lines[i+1])
+		}
+		tasks[i] = *NewTask(TaskId_t(i+1), cost)
+	}
+
+	for _, line := range lines[num_tasks+1:] {
+		pair := strings.Split(line, ",")
+		if len(pair) != 2 {
+			return nil, errors.New("Bad precedence pair " + line)
+		}
+		lhs, lhs_err := strconv.Atoi(strings.TrimSpace(pair[0]))
+		rhs, rhs_err := strconv.Atoi(strings.TrimSpace(pair[1]))
+		if lhs_err != nil || rhs_err != nil {
+			return nil, errors.New("Bad precedence pair " + line)
+		}
+		if lhs == -1 && rhs == -1 {
+			break
+		}
+		if lhs < 1 || lhs > num_tasks || rhs < 1 || rhs > num_tasks {
+			return nil, errors.New("Unknown task in precedence pair " + line)
+		}
+		tasks[rhs-1].prereqs = append(tasks[rhs-1].prereqs, TaskId_t(lhs))
+	}
+
+	var task_strings []string
+	for i, _ := range tasks {
+		task_strings = append(task_strings, tasks[i].ToStr())
+	}
+	return task_strings, nil
+}
+
+// Writes spec lines to a file, one task per line
+func WriteSpecToPath(spec_path string, task_strings []string) {
+	specs_file, err := os.Create(spec_path)
+	if err != nil {
+		log.Fatal("Failed to create file at " + spec_path)
+	}
+	defer specs_file.Close()
+
+	writer := bufio.NewWriter(specs_file)
+	for _, line := range task_strings {
+		writer.WriteString(line + "\n")
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Fatal("Failed to write file at " + spec_path)
+	}
+}
+
 func AreStringsSame(lhs, rhs []string) bool {
 	if lhs == nil && rhs == nil {
 		return true
@@ -99,6 +187,16 @@ func AreTaskIdsSame(lhs, rhs []TaskId_t) bool {
 	return true
 }
 
+// Returns the position of a task id in a slice or -1 if it isn't there
+func indexOfTaskId(ids []TaskId_t, query TaskId_t) int {
+	for i, ele := range ids {
+		if ele == query {
+			return i
+		}
+	}
+	return -1
+}
+
 func taskIdsToStr(ids []TaskId_t) string {
 	str := ""
 	if len(ids) == 0 {
@@ -113,6 +211,16 @@ func taskIdsToStr(ids []TaskId_t) string {
 	return strings.TrimSpace(str)
 }
 
+// Formats a task time with at least one decimal place, like "20.0" or "2.25", so
+// specs written back keep the precision they were read with
+func costToStr(cost float64) string {
+	str := strconv.FormatFloat(cost, 'f', -1, 64)
+	if !strings.Contains(str, ".") {
+		str += ".0"
+	}
+	return str
+}
+
 // ###########
 // Functions for getting permutations of "working set" of task ids
 // ###########